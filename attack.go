@@ -34,7 +34,7 @@ var errAttackDoTimedOut = e.New("Attack Do(ctx) timedout")
 // attack calls attacker.Do upon each received next token, forever
 // attack aborts the loop on a quit receive
 // attack sends a result on the results channel after each call.
-func attack(attacker Attack, next, quit <-chan bool, results chan<- result, timeout time.Duration) {
+func attack(attacker Attack, tracer *Tracer, next, quit <-chan bool, results chan<- result, timeout time.Duration) {
 	for {
 		select {
 		case <-next:
@@ -42,6 +42,7 @@ func attack(attacker Attack, next, quit <-chan bool, results chan<- result, time
 			done := make(chan DoResult)
 			ctx, cancel := context.WithTimeout(context.Background(), timeout)
 			defer cancel()
+			ctx, span := tracer.Start(ctx, "Do", spanKindInternal)
 			go func() {
 				done <- attacker.Do(ctx)
 			}()
@@ -53,6 +54,11 @@ func attack(attacker Attack, next, quit <-chan bool, results chan<- result, time
 			case dor = <-done:
 			}
 			end := time.Now()
+			span.SetName(dor.RequestLabel)
+			span.SetAttribute("loadgen.request_label", dor.RequestLabel)
+			span.SetAttribute("http.status_code", dor.StatusCode)
+			span.SetError(dor.Error)
+			span.End()
 			results <- result{
 				doResult: dor,
 				begin:    begin,
@@ -13,7 +13,7 @@ func TestAttack(t *testing.T) {
 	quit := make(chan bool)
 	results := make(chan result)
 
-	go attack(attacker, next, quit, results, 1*time.Second)
+	go attack(attacker, nil, next, quit, results, 1*time.Second)
 
 	next <- true
 	r := <-results
@@ -34,7 +34,7 @@ func TestAttackTimeout(t *testing.T) {
 	quit := make(chan bool)
 	results := make(chan result)
 
-	go attack(attacker, next, quit, results, 1*time.Second)
+	go attack(attacker, nil, next, quit, results, 1*time.Second)
 
 	next <- true
 	r := <-results
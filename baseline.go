@@ -0,0 +1,324 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// BaselineDir is a dir inside reports dir where baselines are stored as <suite>/<environment>.json
+	BaselineDir = "baselines"
+	// EnvironmentEnv overrides environment key of suite config
+	EnvironmentEnv     = "LOADGEN_ENV"
+	defaultEnvironment = "default"
+)
+
+// BaselineKey identifies baselines of one suite profile on one environment,
+// so runs of prod-min.yaml and stage.yaml never override each other
+type BaselineKey struct {
+	Suite       string `json:"suite"`
+	Environment string `json:"environment"`
+}
+
+func (k BaselineKey) String() string {
+	return k.Suite + "/" + k.Environment
+}
+
+// CurrentBaselineKey returns key of loaded suite config, environment is taken from LOADGEN_ENV
+// or environment config key
+func CurrentBaselineKey() BaselineKey {
+	env := os.Getenv(EnvironmentEnv)
+	if env == "" {
+		env = viper.GetString("environment")
+	}
+	if env == "" {
+		env = defaultEnvironment
+	}
+	return BaselineKey{Suite: SuiteName(), Environment: env}
+}
+
+// HandleBaseline is a history of green runs of one handle
+type HandleBaseline struct {
+	// Runs are ts of green runs, oldest first
+	Runs []int64 `json:"runs"`
+	// Pinned runs are used as baseline instead of the rolling window when set
+	Pinned []int64 `json:"pinned,omitempty"`
+}
+
+// Window returns ts of runs used as baseline: pinned runs or last n green runs
+func (hb *HandleBaseline) Window(n int) []int64 {
+	if len(hb.Pinned) > 0 {
+		return hb.Pinned
+	}
+	if len(hb.Runs) > n {
+		return hb.Runs[len(hb.Runs)-n:]
+	}
+	return hb.Runs
+}
+
+// Baseline holds green runs history of every handle of suite on environment
+type Baseline struct {
+	BaselineKey
+	Handles map[string]*HandleBaseline `json:"handles"`
+}
+
+func baselineName(key BaselineKey) string {
+	return path.Join(BaselineDir, key.Suite, key.Environment+".json")
+}
+
+// LoadBaseline loads baseline by key, missing baseline is empty
+func LoadBaseline(store ReportStore, key BaselineKey) (*Baseline, error) {
+	b := &Baseline{BaselineKey: key, Handles: map[string]*HandleBaseline{}}
+	data, err := store.Get(baselineName(key))
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("failed to decode baseline %s: %s", key, err)
+	}
+	if b.Handles == nil {
+		b.Handles = map[string]*HandleBaseline{}
+	}
+	return b, nil
+}
+
+// Save writes baseline to report store
+func (b *Baseline) Save(store ReportStore) error {
+	data, err := json.MarshalIndent(b, "", "    ")
+	if err != nil {
+		return err
+	}
+	return store.Put(baselineName(b.BaselineKey), data)
+}
+
+func (b *Baseline) handle(handleName string) *HandleBaseline {
+	hb, ok := b.Handles[handleName]
+	if !ok {
+		hb = &HandleBaseline{}
+		b.Handles[handleName] = hb
+	}
+	return hb
+}
+
+// AddRun appends green run to handle history, only last baselineRetention() runs are kept
+func (b *Baseline) AddRun(handleName string, ts int64) {
+	hb := b.handle(handleName)
+	hb.Runs = append(hb.Runs, ts)
+	if keep := baselineRetention(); len(hb.Runs) > keep {
+		hb.Runs = append([]int64(nil), hb.Runs[len(hb.Runs)-keep:]...)
+	}
+}
+
+// Pin fixes handle baseline to runs
+func (b *Baseline) Pin(handleName string, ts ...int64) {
+	b.handle(handleName).Pinned = append([]int64(nil), ts...)
+}
+
+// Unpin returns handle baseline to the rolling window of green runs
+func (b *Baseline) Unpin(handleName string) {
+	b.handle(handleName).Pinned = nil
+}
+
+// Promote makes run the only baseline run of handle, e.g. when slowdown is accepted,
+// rolling window starts from it
+func (b *Baseline) Promote(handleName string, ts int64) {
+	hb := b.handle(handleName)
+	hb.Runs = []int64{ts}
+	hb.Pinned = nil
+}
+
+// ListBaselines returns all baselines in report store
+func ListBaselines(store ReportStore) ([]*Baseline, error) {
+	names, err := store.List(BaselineDir + "/")
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*Baseline, 0, len(names))
+	for _, name := range names {
+		parts := strings.Split(strings.TrimPrefix(name, BaselineDir+"/"), "/")
+		if len(parts) != 2 || !strings.HasSuffix(parts[1], ".json") {
+			continue
+		}
+		key := BaselineKey{Suite: parts[0], Environment: strings.TrimSuffix(parts[1], ".json")}
+		b, err := LoadBaseline(store, key)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, b)
+	}
+	return res, nil
+}
+
+// WriteBaselines writes table of baselines with baseline window of every handle
+func WriteBaselines(w io.Writer, baselines []*Baseline, n int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "suite\tenvironment\thandle\tgreen runs\tbaseline runs\tpinned")
+	for _, b := range baselines {
+		handles := make([]string, 0, len(b.Handles))
+		for h := range b.Handles {
+			handles = append(handles, h)
+		}
+		sort.Strings(handles)
+		for _, h := range handles {
+			hb := b.Handles[h]
+			window := make([]string, 0, n)
+			for _, ts := range hb.Window(n) {
+				window = append(window, strconv.FormatInt(ts, 10))
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%t\n", b.Suite, b.Environment, h, len(hb.Runs), strings.Join(window, ","), len(hb.Pinned) > 0)
+		}
+	}
+	return tw.Flush()
+}
+
+// baselineRuns returns checks.baseline_runs or default
+func baselineRuns() int {
+	n := viper.GetInt("checks.baseline_runs")
+	if n <= 0 {
+		return defaultBaselineRuns
+	}
+	return n
+}
+
+// baselineRetention returns number of green runs kept in handle history, not less than baseline runs
+func baselineRetention() int {
+	if n := baselineRuns(); n > minBaselineRetention {
+		return n
+	}
+	return minBaselineRetention
+}
+
+func runBaselineCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", Commands["baseline"].Usage)
+		os.Exit(2)
+	}
+	action := args[0]
+	switch action {
+	case "list", "pin", "unpin", "promote":
+	default:
+		log.Fatalf("unknown baseline action [%s], possible values are {list,pin,unpin,promote}", action)
+	}
+	fs := newCommandFlagSet("baseline")
+	dir := fs.String("dir", filepath.Join("load", "reports"), "reports dir of filesystem report store")
+	cfgPath := fs.String("config", "", "suite config with reports.store settings, filesystem store in -dir is used when empty")
+	suite := fs.String("suite", "", "suite name, config file name without extension, e.g. prod-min")
+	env := fs.String("env", "", "environment name, default environment for pin, unpin and promote when empty")
+	handle := fs.String("handle", "", "handle name, all handles of the runs when empty")
+	n := fs.Int("n", baselineRuns(), "number of last green runs in rolling window")
+	parseCommandArgs(fs, args[1:], 0)
+	store := commandReportStore(*dir, *cfgPath)
+
+	if action == "list" {
+		baselines, err := ListBaselines(store)
+		if err != nil {
+			log.Fatal(err)
+		}
+		filtered := baselines[:0]
+		for _, b := range baselines {
+			if (*suite == "" || b.Suite == *suite) && (*env == "" || b.Environment == *env) {
+				filtered = append(filtered, b)
+			}
+		}
+		if err := WriteBaselines(os.Stdout, filtered, *n); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *suite == "" {
+		log.Fatal("please set -suite")
+	}
+	if *env == "" {
+		*env = defaultEnvironment
+	}
+	key := BaselineKey{Suite: *suite, Environment: *env}
+	b, err := LoadBaseline(store, key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	runs := make([]int64, 0, fs.NArg())
+	for _, a := range fs.Args() {
+		ts, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			log.Fatalf("run must be report ts: %s", err)
+		}
+		runs = append(runs, ts)
+	}
+	handles, err := baselineCommandHandles(store, b, *handle, runs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, h := range handles {
+		switch action {
+		case "pin":
+			pinned := runs
+			if len(pinned) == 0 {
+				pinned = b.handle(h).Window(*n)
+			}
+			b.Pin(h, pinned...)
+			log.Printf("[ %s ] %s baseline pinned to runs %v", key, h, pinned)
+		case "unpin":
+			b.Unpin(h)
+			log.Printf("[ %s ] %s baseline uses last %d green runs", key, h, *n)
+		case "promote":
+			if len(runs) != 1 {
+				log.Fatal("please set one run ts to promote")
+			}
+			b.Promote(h, runs[0])
+			log.Printf("[ %s ] %s run %d promoted to baseline", key, h, runs[0])
+		}
+	}
+	if err := b.Save(store); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// baselineCommandHandles returns handles command is applied to: the handle flag, handles having reports
+// of every given run or all handles of baseline
+func baselineCommandHandles(store ReportStore, b *Baseline, handle string, runs []int64) ([]string, error) {
+	if handle != "" {
+		return []string{handle}, nil
+	}
+	var handles []string
+	if len(runs) == 0 {
+		for h := range b.Handles {
+			handles = append(handles, h)
+		}
+		sort.Strings(handles)
+		return handles, nil
+	}
+	byTs, err := storedReportsByTs(store)
+	if err != nil {
+		return nil, err
+	}
+	count := map[string]int{}
+	for _, ts := range runs {
+		for _, h := range byTs[ts] {
+			count[h]++
+		}
+	}
+	for h, c := range count {
+		if c == len(runs) {
+			handles = append(handles, h)
+		}
+	}
+	if len(handles) == 0 {
+		return nil, fmt.Errorf("no handle reports of runs %v found", runs)
+	}
+	sort.Strings(handles)
+	return handles, nil
+}
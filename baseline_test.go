@@ -0,0 +1,145 @@
+package loadgen
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestBaselinesByKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prod := NewLoadManager()
+	prod.ReportDir = dir
+	prod.BaselineKey = BaselineKey{Suite: "prod-min", Environment: "stage"}
+	stage := NewLoadManager()
+	stage.ReportDir = dir
+	stage.BaselineKey = BaselineKey{Suite: "stage", Environment: "stage"}
+	for _, ts := range []int64{100, 200, 300} {
+		writeTestReport(t, dir, "transfer", ts, testRunReport(time.Millisecond))
+		prod.WriteLastSuccess("transfer", ts)
+	}
+	stage.WriteLastSuccess("transfer", 400)
+
+	history, err := prod.SuccessHistoryForHandle("transfer", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(history), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := history[1], int64(300); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	history, err = stage.SuccessHistoryForHandle("transfer", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := history[0], int64(400); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	baselines, err := ListBaselines(NewFileReportStore(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(baselines), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	var out bytes.Buffer
+	if err := WriteBaselines(&out, baselines, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "200,300") {
+		t.Errorf("expected rolling window in %s", out.String())
+	}
+}
+
+func TestPinAndPromoteBaseline(t *testing.T) {
+	b := &Baseline{Handles: map[string]*HandleBaseline{}}
+	for _, ts := range []int64{100, 200, 300} {
+		b.AddRun("transfer", ts)
+	}
+	b.Pin("transfer", 100)
+	if got, want := b.Handles["transfer"].Window(5)[0], int64(100); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	b.AddRun("transfer", 400)
+	if got, want := len(b.Handles["transfer"].Window(5)), 1; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	b.Unpin("transfer")
+	if got, want := len(b.Handles["transfer"].Window(5)), 4; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	b.Promote("transfer", 500)
+	b.AddRun("transfer", 600)
+	if got, want := len(b.Handles["transfer"].Window(5)), 2; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestBaselineRetention(t *testing.T) {
+	defer viper.Reset()
+	b := &Baseline{Handles: map[string]*HandleBaseline{}}
+	for ts := int64(1); ts <= minBaselineRetention+10; ts++ {
+		b.AddRun("transfer", ts)
+	}
+	runs := b.Handles["transfer"].Runs
+	if got, want := len(runs), minBaselineRetention; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := runs[0], int64(11); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	viper.Set("checks.baseline_runs", minBaselineRetention+50)
+	for ts := int64(1); ts <= minBaselineRetention+60; ts++ {
+		b.AddRun("balance", ts)
+	}
+	if got, want := len(b.Handles["balance"].Runs), minBaselineRetention+50; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestLegacyHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "transfer_last"), []byte("100"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	writeTestReport(t, dir, "transfer", 100, testRunReport(time.Millisecond))
+	lm := NewLoadManager()
+	lm.ReportDir = dir
+	lm.BaselineKey = BaselineKey{Suite: "prod-min", Environment: "stage"}
+	if _, err := lm.LastSuccessReportForHandle("transfer"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMedianBaseline(t *testing.T) {
+	baselines := []*Metrics{
+		{Latencies: LatencyMetrics{P50: 10 * time.Millisecond}},
+		{Latencies: LatencyMetrics{P50: 100 * time.Millisecond}},
+		{Latencies: LatencyMetrics{P50: 12 * time.Millisecond}},
+	}
+	current := &Metrics{Latencies: LatencyMetrics{P50: 15 * time.Millisecond}}
+	c := NewDegradationCheck(current, baselines, 1.2, 0.01)
+	if got, want := c.BaselineP50, 12*time.Millisecond; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if !c.Degraded {
+		t.Errorf("expected degradation against median: %s", c)
+	}
+}
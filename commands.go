@@ -0,0 +1,103 @@
+package loadgen
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+// Command is a subcommand of load binary, e.g. go run load/cmd/load/main.go html load/reports
+type Command struct {
+	Usage string
+	Run   func(args []string)
+}
+
+// Commands registered subcommands, CIRun runs a command when its name is the first argument
+var Commands map[string]Command
+
+func init() {
+	Commands = map[string]Command{
+		"html":     {"html [-o report.html] [-title title] <report.json|report dir>...", runHTMLCommand},
+		"baseline": {"baseline list|pin|unpin|promote [-dir load/reports] [-config suite.yaml] [-suite prod-min] [-env stage] [-handle name] [-n 5] [run ts...]", runBaselineCommand},
+		"trend":    {"trend [-dir load/reports] [-config suite.yaml] [-handle name] [-label name] [-since 720h] [-format csv|json|html] [-o trend.csv]", runTrendCommand},
+		"validate": {"validate [-set key=value] [-rps-scale factor] <suite.yaml>...", runValidateCommand},
+		"compare":  {"compare [-format text|markdown|json] [-latency-threshold 10] [-fail] <baseline report.json|report dir> <report.json|report dir>...", runCompareCommand},
+	}
+}
+
+// RunCommand runs subcommand if first argument is a command name, returns false otherwise
+func RunCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if args[0] == "help" {
+		commandsUsage()
+		os.Exit(0)
+	}
+	cmd, ok := Commands[args[0]]
+	if !ok {
+		return false
+	}
+	cmd.Run(args[1:])
+	return true
+}
+
+func commandsUsage() {
+	fmt.Fprintln(os.Stderr, "commands:")
+	names := make([]string, 0, len(Commands))
+	for name := range Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", Commands[name].Usage)
+	}
+}
+
+func newCommandFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", Commands[name].Usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func parseCommandArgs(fs *flag.FlagSet, args []string, minArgs int) {
+	if err := fs.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+	if fs.NArg() < minArgs {
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+func runHTMLCommand(args []string) {
+	fs := newCommandFlagSet("html")
+	out := fs.String("o", "report.html", "output html file")
+	title := fs.String("title", "Load test report", "report title")
+	parseCommandArgs(fs, args, 1)
+
+	reports, err := LoadRunReports(fs.Args()...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := WriteHTMLReportFile(*out, *title, reports); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("html report written to %s", *out)
+}
+
+// commandReportStore returns report store of suite config or filesystem store in dir
+func commandReportStore(dir string, cfgPath string) ReportStore {
+	if cfgPath == "" {
+		return NewFileReportStore(dir)
+	}
+	if err := ReadSuiteConfig(cfgPath); err != nil {
+		log.Fatalf("Failed to readIn viper: %s\n", err)
+	}
+	return ReportStoreFromConfig(dir)
+}
@@ -0,0 +1,325 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	CompareFormatText     = "text"
+	CompareFormatMarkdown = "markdown"
+	CompareFormatJSON     = "json"
+
+	CompareRegression  = "regression"
+	CompareImprovement = "improvement"
+)
+
+// ReportSet is a named set of handle reports, e.g. one suite run
+type ReportSet struct {
+	Name    string
+	Reports map[string]*RunReport
+}
+
+// LoadReportSet loads report file or the latest suite in reports dir
+func LoadReportSet(path string) (ReportSet, error) {
+	reports, err := LoadRunReports(path)
+	if err != nil {
+		return ReportSet{}, err
+	}
+	return ReportSet{Name: filepath.Clean(path), Reports: reports}, nil
+}
+
+// MetricDelta is a baseline and current value of one metric
+type MetricDelta struct {
+	Base    float64 `json:"base"`
+	Current float64 `json:"current"`
+	// Delta is relative change in percents, for success it is absolute change in percentage points
+	Delta float64 `json:"delta"`
+	// Verdict is regression, improvement or empty when change is within threshold
+	Verdict string `json:"verdict,omitempty"`
+}
+
+// ComparisonRow compares metrics of one handle label or of label requests with one tag value
+type ComparisonRow struct {
+	Handle string `json:"handle"`
+	Label  string `json:"label"`
+	// Tag is dimension=value of label metrics breakdown, empty for the whole label
+	Tag     string                 `json:"tag,omitempty"`
+	Run     string                 `json:"run"`
+	Missing bool                   `json:"missing,omitempty"`
+	Metrics map[string]MetricDelta `json:"metrics,omitempty"`
+}
+
+// CompareOptions sets regression highlighting thresholds
+type CompareOptions struct {
+	// LatencyThresholdPercent relative latency change to highlight
+	LatencyThresholdPercent float64
+	// RateThresholdPercent relative rate change to highlight
+	RateThresholdPercent float64
+	// SuccessThresholdPoints success change in percentage points to highlight
+	SuccessThresholdPoints float64
+	// ErrorsThresholdPercent relative change of failed requests to highlight
+	ErrorsThresholdPercent float64
+}
+
+// DefaultCompareOptions highlights 10% latency, rate and failed requests changes and 1 point success changes
+func DefaultCompareOptions() CompareOptions {
+	return CompareOptions{
+		LatencyThresholdPercent: 10,
+		RateThresholdPercent:    10,
+		SuccessThresholdPoints:  1,
+		ErrorsThresholdPercent:  10,
+	}
+}
+
+var comparedMetrics = []string{"p50", "p95", "p99", "max", "rate", "success", "errors"}
+
+func relativeDelta(base, current float64) float64 {
+	if base == 0 {
+		if current == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (current - base) / base * 100
+}
+
+func latencyDelta(base, current time.Duration, threshold float64) MetricDelta {
+	d := MetricDelta{
+		Base:    float64(base) / float64(time.Millisecond),
+		Current: float64(current) / float64(time.Millisecond),
+	}
+	d.Delta = relativeDelta(d.Base, d.Current)
+	switch {
+	case d.Delta > threshold:
+		d.Verdict = CompareRegression
+	case d.Delta < -threshold:
+		d.Verdict = CompareImprovement
+	}
+	return d
+}
+
+func compareMetrics(base, current *Metrics, o CompareOptions) map[string]MetricDelta {
+	res := map[string]MetricDelta{
+		"p50": latencyDelta(base.Latencies.P50, current.Latencies.P50, o.LatencyThresholdPercent),
+		"p95": latencyDelta(base.Latencies.P95, current.Latencies.P95, o.LatencyThresholdPercent),
+		"p99": latencyDelta(base.Latencies.P99, current.Latencies.P99, o.LatencyThresholdPercent),
+		"max": latencyDelta(base.Latencies.Max, current.Latencies.Max, o.LatencyThresholdPercent),
+	}
+	rate := MetricDelta{Base: base.Rate, Current: current.Rate, Delta: relativeDelta(base.Rate, current.Rate)}
+	switch {
+	case rate.Delta < -o.RateThresholdPercent:
+		rate.Verdict = CompareRegression
+	case rate.Delta > o.RateThresholdPercent:
+		rate.Verdict = CompareImprovement
+	}
+	res["rate"] = rate
+	success := MetricDelta{Base: base.Success * 100, Current: current.Success * 100}
+	success.Delta = success.Current - success.Base
+	switch {
+	case success.Delta < -o.SuccessThresholdPoints:
+		success.Verdict = CompareRegression
+	case success.Delta > o.SuccessThresholdPoints:
+		success.Verdict = CompareImprovement
+	}
+	res["success"] = success
+	// errors are failed requests, not distinct error messages
+	errs := MetricDelta{Base: float64(failedRequests(base)), Current: float64(failedRequests(current))}
+	errs.Delta = relativeDelta(errs.Base, errs.Current)
+	switch {
+	case errs.Delta > o.ErrorsThresholdPercent:
+		errs.Verdict = CompareRegression
+	case errs.Delta < -o.ErrorsThresholdPercent:
+		errs.Verdict = CompareImprovement
+	}
+	res["errors"] = errs
+	return res
+}
+
+// CompareReportSets compares every handle label and its breakdown by tags of candidates to baseline
+func CompareReportSets(baseline ReportSet, candidates []ReportSet, o CompareOptions) []ComparisonRow {
+	var rows []ComparisonRow
+	for _, c := range candidates {
+		keys := map[[3]string]bool{}
+		for _, set := range []ReportSet{baseline, c} {
+			for h, r := range set.Reports {
+				for l, m := range r.Metrics {
+					keys[[3]string{h, l, ""}] = true
+					for dim, values := range m.Breakdown {
+						for value := range values {
+							keys[[3]string{h, l, dim + "=" + value}] = true
+						}
+					}
+				}
+			}
+		}
+		sorted := make([][3]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			for n := range sorted[i] {
+				if sorted[i][n] != sorted[j][n] {
+					return sorted[i][n] < sorted[j][n]
+				}
+			}
+			return false
+		})
+		for _, k := range sorted {
+			row := ComparisonRow{Handle: k[0], Label: k[1], Tag: k[2], Run: c.Name}
+			base := reportSetMetrics(baseline, k[0], k[1], k[2])
+			current := reportSetMetrics(c, k[0], k[1], k[2])
+			if base == nil || current == nil {
+				row.Missing = true
+			} else {
+				row.Metrics = compareMetrics(base, current, o)
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// reportSetMetrics returns metrics of handle label, for dimension=value tag metrics of label breakdown
+func reportSetMetrics(set ReportSet, handle, label, tag string) *Metrics {
+	r, ok := set.Reports[handle]
+	if !ok {
+		return nil
+	}
+	m, ok := r.Metrics[label]
+	if !ok || tag == "" {
+		return m
+	}
+	kv := strings.SplitN(tag, "=", 2)
+	return m.Breakdown[kv[0]][kv[1]]
+}
+
+// rowLabel returns label of comparison row with tag of breakdown
+func rowLabel(row ComparisonRow) string {
+	if row.Tag == "" {
+		return row.Label
+	}
+	return row.Label + " " + row.Tag
+}
+
+// Regressions returns true if any compared metric regressed
+func Regressions(rows []ComparisonRow) bool {
+	for _, row := range rows {
+		for _, d := range row.Metrics {
+			if d.Verdict == CompareRegression {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatDelta(name string, d MetricDelta, markdown bool) string {
+	var s string
+	switch name {
+	case "p50", "p95", "p99", "max":
+		s = fmt.Sprintf("%.2fms -> %.2fms (%+.1f%%)", d.Base, d.Current, d.Delta)
+	case "rate":
+		s = fmt.Sprintf("%.2f -> %.2f (%+.1f%%)", d.Base, d.Current, d.Delta)
+	case "success":
+		s = fmt.Sprintf("%.2f%% -> %.2f%% (%+.2f)", d.Base, d.Current, d.Delta)
+	default:
+		s = fmt.Sprintf("%.0f -> %.0f (%+.1f%%)", d.Base, d.Current, d.Delta)
+	}
+	switch d.Verdict {
+	case CompareRegression:
+		if markdown {
+			return "**" + s + "** :red_circle:"
+		}
+		return s + " !"
+	case CompareImprovement:
+		if markdown {
+			return s + " :green_circle:"
+		}
+		return s + " +"
+	}
+	return s
+}
+
+// WriteComparison writes comparison rows in text, markdown or json format
+func WriteComparison(w io.Writer, rows []ComparisonRow, format string) error {
+	switch format {
+	case CompareFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case CompareFormatMarkdown:
+		header := append([]string{"run", "handle", "label"}, comparedMetrics...)
+		fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(header)))
+		for _, row := range rows {
+			cells := []string{row.Run, row.Handle, rowLabel(row)}
+			for _, m := range comparedMetrics {
+				cells = append(cells, compareCell(row, m, true))
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		}
+		return nil
+	case CompareFormatText, "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "%s\n", strings.Join(append([]string{"run", "handle", "label"}, comparedMetrics...), "\t"))
+		for _, row := range rows {
+			cells := []string{row.Run, row.Handle, rowLabel(row)}
+			for _, m := range comparedMetrics {
+				cells = append(cells, compareCell(row, m, false))
+			}
+			fmt.Fprintf(tw, "%s\n", strings.Join(cells, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "! - regression, + - improvement")
+		return nil
+	default:
+		return fmt.Errorf("unknown format [%s], possible values are {text,markdown,json}", format)
+	}
+}
+
+func compareCell(row ComparisonRow, metric string, markdown bool) string {
+	if row.Missing {
+		return "missing"
+	}
+	return formatDelta(metric, row.Metrics[metric], markdown)
+}
+
+func runCompareCommand(args []string) {
+	fs := newCommandFlagSet("compare")
+	format := fs.String("format", CompareFormatText, "output format {text,markdown,json}")
+	o := DefaultCompareOptions()
+	fs.Float64Var(&o.LatencyThresholdPercent, "latency-threshold", o.LatencyThresholdPercent, "relative latency change in percents highlighted as regression or improvement")
+	fs.Float64Var(&o.RateThresholdPercent, "rate-threshold", o.RateThresholdPercent, "relative rate change in percents highlighted as regression or improvement")
+	fs.Float64Var(&o.SuccessThresholdPoints, "success-threshold", o.SuccessThresholdPoints, "success change in percentage points highlighted as regression or improvement")
+	fs.Float64Var(&o.ErrorsThresholdPercent, "errors-threshold", o.ErrorsThresholdPercent, "relative change of failed requests in percents highlighted as regression or improvement")
+	failOnRegression := fs.Bool("fail", false, "exit with non-zero code when regression is found")
+	parseCommandArgs(fs, args, 2)
+
+	sets := make([]ReportSet, 0, fs.NArg())
+	for _, p := range fs.Args() {
+		set, err := LoadReportSet(p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		sets = append(sets, set)
+	}
+	rows := CompareReportSets(sets[0], sets[1:], o)
+	if err := WriteComparison(os.Stdout, rows, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *failOnRegression && Regressions(rows) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,119 @@
+package loadgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareReportSets(t *testing.T) {
+	baseline := ReportSet{Name: "old", Reports: map[string]*RunReport{
+		"transfer": testRunReport(10 * time.Millisecond),
+		"balance":  testRunReport(10 * time.Millisecond),
+	}}
+	current := ReportSet{Name: "new", Reports: map[string]*RunReport{
+		"transfer": testRunReport(20 * time.Millisecond),
+		"balance":  testRunReport(5 * time.Millisecond),
+	}}
+	rows := CompareReportSets(baseline, []ReportSet{current}, DefaultCompareOptions())
+	if got, want := len(rows), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := rows[0].Handle, "balance"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := rows[0].Metrics["p50"].Verdict, CompareImprovement; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := rows[1].Metrics["p50"].Verdict, CompareRegression; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := rows[1].Metrics["p50"].Delta, 100.0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if !Regressions(rows) {
+		t.Error("expected regressions")
+	}
+}
+
+func TestWriteComparison(t *testing.T) {
+	baseline := ReportSet{Name: "old", Reports: map[string]*RunReport{"transfer": testRunReport(10 * time.Millisecond)}}
+	current := ReportSet{Name: "new", Reports: map[string]*RunReport{"transfer": testRunReport(20 * time.Millisecond)}}
+	rows := CompareReportSets(baseline, []ReportSet{current}, DefaultCompareOptions())
+
+	var md bytes.Buffer
+	if err := WriteComparison(&md, rows, CompareFormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md.String(), "**10.00ms -> 20.00ms (+100.0%)** :red_circle:") {
+		t.Errorf("unexpected markdown: %s", md.String())
+	}
+	var js bytes.Buffer
+	if err := WriteComparison(&js, rows, CompareFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []ComparisonRow
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteComparison(&js, rows, "xml"); err == nil {
+		t.Error("expected unknown format error")
+	}
+}
+
+func TestCompareBreakdown(t *testing.T) {
+	tagged := func(p50 time.Duration) *RunReport {
+		r := testRunReport(p50)
+		m := new(Metrics)
+		now := time.Now()
+		m.add(result{begin: now, end: now.Add(p50), elapsed: p50})
+		m.updateLatencies()
+		r.Metrics["transfer"].Breakdown = map[string]map[string]*Metrics{"region": {"eu": m}}
+		return r
+	}
+	baseline := ReportSet{Name: "old", Reports: map[string]*RunReport{"transfer": tagged(10 * time.Millisecond)}}
+	current := ReportSet{Name: "new", Reports: map[string]*RunReport{"transfer": tagged(20 * time.Millisecond)}}
+	rows := CompareReportSets(baseline, []ReportSet{current}, DefaultCompareOptions())
+	if got, want := len(rows), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := rows[1].Tag, "region=eu"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := rows[1].Metrics["p50"].Verdict, CompareRegression; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	var buf bytes.Buffer
+	if err := WriteComparison(&buf, rows, CompareFormatText); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "transfer region=eu") {
+		t.Errorf("expected tag row in comparison: %s", buf.String())
+	}
+}
+
+func TestCompareFailedRequests(t *testing.T) {
+	base, current := testRunReport(10*time.Millisecond), testRunReport(10*time.Millisecond)
+	// same error message of many failed requests
+	base.Metrics["transfer"].Requests, base.Metrics["transfer"].Success = 10000, 0.99
+	base.Metrics["transfer"].Errors = []string{"timeout"}
+	current.Metrics["transfer"].Requests, current.Metrics["transfer"].Success = 10000, 0.5
+	current.Metrics["transfer"].Errors = []string{"timeout"}
+	rows := CompareReportSets(
+		ReportSet{Name: "old", Reports: map[string]*RunReport{"transfer": base}},
+		[]ReportSet{{Name: "new", Reports: map[string]*RunReport{"transfer": current}}},
+		DefaultCompareOptions(),
+	)
+	errs := rows[0].Metrics["errors"]
+	if got, want := errs.Base, 100.0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := errs.Current, 5000.0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := errs.Verdict, CompareRegression; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
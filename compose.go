@@ -0,0 +1,328 @@
+package loadgen
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	configDefaultsKey = "defaults"
+	configIncludeKey  = "include"
+	configExtendsKey  = "extends"
+)
+
+// envRefRe matches ${VAR} and ${VAR:-default}, $${VAR} is kept as is without $
+var envRefRe = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// configSource is one file of composed suite config
+type configSource struct {
+	path string
+	// raw is interpolated yaml document of the file
+	raw interface{}
+	idx yamlIndex
+}
+
+// composedConfig is suite config with base profiles merged and defaults applied to handles
+type composedConfig struct {
+	data map[interface{}]interface{}
+	// sources are suite config file and all included files
+	sources []configSource
+}
+
+// ReadSuiteConfig reads suite config into viper, yaml configs are composed:
+// extends and include files are merged under the config, defaults are applied to every handle
+// and ${ENV_VAR:-default} references are replaced with environment variables
+func ReadSuiteConfig(path string) error {
+	viper.SetConfigFile(path)
+	if !isYAMLFile(path) {
+		return viper.ReadInConfig()
+	}
+	cfg, err := composeSuiteConfig(path)
+	if err != nil {
+		return err
+	}
+	return cfg.readInto(viper.GetViper())
+}
+
+func (c *composedConfig) readInto(v *viper.Viper) error {
+	data, err := yaml.Marshal(c.data)
+	if err != nil {
+		return err
+	}
+	v.SetConfigType("yaml")
+	return v.ReadConfig(bytes.NewReader(data))
+}
+
+func composeSuiteConfig(path string) (*composedConfig, error) {
+	c := &composedConfig{}
+	data, err := c.load(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyHandleDefaults(data); err != nil {
+		return nil, ConfigProblem{File: path, Line: c.sources[0].idx.line(configDefaultsKey), Path: configDefaultsKey, Message: err.Error()}
+	}
+	c.data = data
+	return c, nil
+}
+
+// load reads file and merges it over its base profiles, chain is used to detect include cycles
+func (c *composedConfig) load(path string, chain []string) (map[interface{}]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range chain {
+		if p == abs {
+			return nil, ConfigProblem{File: path, Message: fmt.Sprintf("include cycle: %s", strings.Join(append(chain, abs), " -> "))}
+		}
+	}
+	chain = append(chain, abs)
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, ConfigProblem{File: path, Message: err.Error()}
+	}
+	var raw interface{}
+	if err := yaml.Unmarshal(text, &raw); err != nil {
+		p := ConfigProblem{File: path, Message: err.Error()}
+		if m := yamlLineRe.FindStringSubmatch(err.Error()); m != nil {
+			p.Line, _ = strconv.Atoi(m[1])
+		}
+		return nil, p
+	}
+	idx := newYAMLIndex(text)
+	if raw, err = interpolateEnv(path, idx, raw, ""); err != nil {
+		return nil, err
+	}
+	src := configSource{path: path, raw: raw, idx: idx}
+	c.sources = append(c.sources, src)
+	doc, ok := raw.(map[interface{}]interface{})
+	if raw != nil && !ok {
+		return nil, ConfigProblem{File: path, Message: "suite config must be a mapping"}
+	}
+	if doc == nil {
+		doc = map[interface{}]interface{}{}
+	}
+
+	if err := unaliasHandles(doc); err != nil {
+		p := err.(ConfigProblem)
+		p.File, p.Line = path, src.idx.line(p.Path)
+		return nil, p
+	}
+
+	var bases []string
+	if extends, ok := doc[configExtendsKey]; ok {
+		s, ok := extends.(string)
+		if !ok {
+			return nil, ConfigProblem{File: path, Line: src.idx.line(configExtendsKey), Path: configExtendsKey, Message: "must be a file path"}
+		}
+		bases = append(bases, s)
+	}
+	if include, ok := doc[configIncludeKey]; ok {
+		switch include := include.(type) {
+		case string:
+			bases = append(bases, include)
+		case []interface{}:
+			for _, i := range include {
+				bases = append(bases, fmt.Sprint(i))
+			}
+		default:
+			return nil, ConfigProblem{File: path, Line: src.idx.line(configIncludeKey), Path: configIncludeKey, Message: "must be a file path or a list of them"}
+		}
+	}
+	merged := map[interface{}]interface{}{}
+	for _, b := range bases {
+		if !filepath.IsAbs(b) {
+			b = filepath.Join(filepath.Dir(path), b)
+		}
+		base, err := c.load(b, chain)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeConfig(merged, base).(map[interface{}]interface{})
+	}
+	delete(doc, configExtendsKey)
+	delete(doc, configIncludeKey)
+	return mergeConfig(merged, doc).(map[interface{}]interface{}), nil
+}
+
+// interpolateEnv replaces ${VAR} and ${VAR:-default} in string values of decoded config with environment variables,
+// values are replaced after parsing, so variables with yaml syntax and references in comments do not change the document,
+// value which is a single reference gets number or bool type of variable, unset variable without default is an error
+func interpolateEnv(path string, idx yamlIndex, node interface{}, key string) (interface{}, error) {
+	switch n := node.(type) {
+	case map[interface{}]interface{}:
+		for k, v := range n {
+			childKey := fmt.Sprint(k)
+			if key != "" {
+				childKey = key + "." + childKey
+			}
+			res, err := interpolateEnv(path, idx, v, childKey)
+			if err != nil {
+				return nil, err
+			}
+			n[k] = res
+		}
+	case []interface{}:
+		for i, v := range n {
+			res, err := interpolateEnv(path, idx, v, fmt.Sprintf("%s[%d]", key, i))
+			if err != nil {
+				return nil, err
+			}
+			n[i] = res
+		}
+	case string:
+		res, missing := interpolateValue(n)
+		if missing != "" {
+			return nil, ConfigProblem{File: path, Line: idx.line(key), Path: key, Message: fmt.Sprintf("environment variable %s is not set, set it or use ${%s:-default}", missing, missing)}
+		}
+		if ref := envRefRe.FindString(n); ref == n && !strings.HasPrefix(ref, "$$") {
+			var typed interface{}
+			if err := yaml.Unmarshal([]byte(res), &typed); err == nil {
+				switch typed.(type) {
+				case int, float64, bool:
+					return typed, nil
+				}
+			}
+		}
+		return res, nil
+	}
+	return node, nil
+}
+
+// interpolateValue replaces references in value, returns name of the first unset variable without default
+func interpolateValue(value string) (string, string) {
+	var missing string
+	res := envRefRe.ReplaceAllStringFunc(value, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		m := envRefRe.FindStringSubmatch(ref)
+		if v, ok := os.LookupEnv(m[1]); ok && (v != "" || m[2] == "") {
+			return v
+		}
+		if m[2] != "" {
+			return m[3]
+		}
+		if missing == "" {
+			missing = m[1]
+		}
+		return ref
+	})
+	return res, missing
+}
+
+// mergeConfig merges over into base: mappings are merged recursively, lists of named items are merged by name,
+// other values of over replace base ones
+func mergeConfig(base, over interface{}) interface{} {
+	if bm, ok := base.(map[interface{}]interface{}); ok {
+		if om, ok := over.(map[interface{}]interface{}); ok {
+			res := make(map[interface{}]interface{}, len(bm)+len(om))
+			for k, v := range bm {
+				res[k] = v
+			}
+			for k, v := range om {
+				if bv, ok := res[k]; ok {
+					res[k] = mergeConfig(bv, v)
+					continue
+				}
+				res[k] = v
+			}
+			return res
+		}
+	}
+	if bl, ok := base.([]interface{}); ok {
+		if ol, ok := over.([]interface{}); ok && namedItems(bl) && namedItems(ol) {
+			res := append([]interface{}{}, bl...)
+			for _, o := range ol {
+				name := o.(map[interface{}]interface{})["name"]
+				merged := false
+				for i, b := range res {
+					if b.(map[interface{}]interface{})["name"] == name {
+						res[i] = mergeConfig(b, o)
+						merged = true
+						break
+					}
+				}
+				if !merged {
+					res = append(res, o)
+				}
+			}
+			return res
+		}
+	}
+	return over
+}
+
+// namedItems returns true if all list items are mappings with name, e.g. handles
+func namedItems(l []interface{}) bool {
+	for _, item := range l {
+		m, ok := item.(map[interface{}]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := m["name"]; !ok {
+			return false
+		}
+	}
+	return len(l) > 0
+}
+
+// unaliasHandles renames duration aliases of handles and defaults to the old keys,
+// so that settings written with either of them are merged
+func unaliasHandles(doc map[interface{}]interface{}) error {
+	if d, ok := doc[configDefaultsKey].(map[interface{}]interface{}); ok {
+		res, err := unaliasSettings(d)
+		if err != nil {
+			return ConfigProblem{Path: configDefaultsKey, Message: err.Error()}
+		}
+		doc[configDefaultsKey] = res
+	}
+	handles, ok := doc["handles"].([]interface{})
+	if !ok {
+		return nil
+	}
+	res := make([]interface{}, len(handles))
+	for i, h := range handles {
+		res[i] = h
+		if m, ok := h.(map[interface{}]interface{}); ok {
+			var err error
+			if res[i], err = unaliasSettings(m); err != nil {
+				return ConfigProblem{Path: fmt.Sprintf("handles[%d]", i), Message: err.Error()}
+			}
+		}
+	}
+	doc["handles"] = res
+	return nil
+}
+
+// applyHandleDefaults merges every handle over defaults section and removes it
+func applyHandleDefaults(doc map[interface{}]interface{}) error {
+	defaults, ok := doc[configDefaultsKey]
+	if !ok {
+		return nil
+	}
+	delete(doc, configDefaultsKey)
+	if _, ok := defaults.(map[interface{}]interface{}); !ok {
+		return fmt.Errorf("must be a mapping of handle settings")
+	}
+	handles, ok := doc["handles"].([]interface{})
+	if !ok {
+		return nil
+	}
+	res := make([]interface{}, len(handles))
+	for i, h := range handles {
+		res[i] = mergeConfig(defaults, h)
+	}
+	doc["handles"] = res
+	return nil
+}
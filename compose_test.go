@@ -0,0 +1,161 @@
+package loadgen
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+func TestComposeSuiteConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer viper.Reset()
+	os.Setenv("LOADGEN_TEST_TARGET", "http://stage:8080")
+	defer os.Unsetenv("LOADGEN_TEST_TARGET")
+
+	writeTestConfig(t, dir, "checks.yaml", `checks:
+  handle_threshold_percent: 1.2
+`)
+	writeTestConfig(t, dir, "base.yaml", `include: [checks.yaml]
+execution_mode: parallel
+generator:
+  target: ${LOADGEN_TEST_TARGET}
+  timeout: ${LOADGEN_TEST_TIMEOUT:-20}
+defaults:
+  attack_time_sec: 60
+  ramp_up_sec: 10
+  max_attackers: 10
+  do_timeout_sec: 5
+handles:
+  - name: transfer
+    rps: 10
+  - name: balance
+    rps: 20
+`)
+	p := writeTestConfig(t, dir, "stage-max.yaml", `extends: base.yaml
+handles:
+  - name: transfer
+    rps: 100
+    max_attackers: 200
+  - name: verify
+    rps: 1
+`)
+	if problems := ValidateSuiteConfigFile(p); len(problems) > 0 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+	if err := ReadSuiteConfig(p); err != nil {
+		t.Fatal(err)
+	}
+	var cfg SuiteConfig
+	if err := viper.Unmarshal(&cfg, viper.DecodeHook(configDecodeHook)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(cfg.Handles), 3; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	transfer := cfg.Handles[0]
+	if got, want := transfer.RPS, 100; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := transfer.MaxAttackers, 200; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := transfer.AttackTimeSec, 60; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := cfg.Handles[2].DoTimeoutSec, 5; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := viper.GetString("generator.target"), "http://stage:8080"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := viper.GetInt("generator.timeout"), 20; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := viper.GetFloat64("checks.handle_threshold_percent"), 1.2; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := SuiteName(), "stage-max"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	p = writeTestConfig(t, dir, "missing-env.yaml", `execution_mode: parallel
+generator:
+  target: ${LOADGEN_TEST_MISSING}
+`)
+	problems := ValidateSuiteConfigFile(p)
+	if got, want := len(problems), 1; got != want {
+		t.Fatalf("got %v want %v", problems, want)
+	}
+	if got, want := problems[0].Line, 3; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	writeTestConfig(t, dir, "a.yaml", "extends: b.yaml\n")
+	p = writeTestConfig(t, dir, "b.yaml", "extends: a.yaml\n")
+	if err := ReadSuiteConfig(p); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("expected include cycle, got %v", err)
+	}
+
+	p = writeTestConfig(t, dir, "bad-defaults.yaml", `extends: base.yaml
+defaults:
+  rampUpSec: 1
+`)
+	problems = ValidateSuiteConfigFile(p)
+	if got, want := len(problems), 1; got != want {
+		t.Fatalf("got %v want %v", problems, want)
+	}
+	if got, want := problems[0].Line, 3; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestInterpolateEnv(t *testing.T) {
+	os.Setenv("LOADGEN_TEST_EMPTY", "")
+	defer os.Unsetenv("LOADGEN_TEST_EMPTY")
+	os.Setenv("LOADGEN_TEST_DSN", "postgres://loader:qw#er: ty@db/app")
+	defer os.Unsetenv("LOADGEN_TEST_DSN")
+	os.Setenv("LOADGEN_TEST_PASSWORD", "*secret")
+	defer os.Unsetenv("LOADGEN_TEST_PASSWORD")
+	text := []byte(`# ${LOADGEN_TEST_MISSING} in comment is ignored
+a: ${LOADGEN_TEST_EMPTY}
+b: ${LOADGEN_TEST_EMPTY:-x}
+c: $${HOME}
+dsn: ${LOADGEN_TEST_DSN}
+password: ${LOADGEN_TEST_PASSWORD}
+rps: ${LOADGEN_TEST_RPS:-30}
+list:
+  - x-${LOADGEN_TEST_EMPTY:-y}
+`)
+	var raw interface{}
+	if err := yaml.Unmarshal(text, &raw); err != nil {
+		t.Fatal(err)
+	}
+	got, err := interpolateEnv("x.yaml", newYAMLIndex(text), raw, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := got.(map[interface{}]interface{})
+	for k, want := range map[string]interface{}{
+		"a":        "",
+		"b":        "x",
+		"c":        "${HOME}",
+		"dsn":      "postgres://loader:qw#er: ty@db/app",
+		"password": "*secret",
+		"rps":      30,
+	} {
+		if got := doc[k]; got != want {
+			t.Errorf("%s: got %#v want %#v", k, got, want)
+		}
+	}
+	if got, want := doc["list"].([]interface{})[0], "x-y"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
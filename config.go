@@ -6,6 +6,8 @@ import (
 	"github.com/spf13/viper"
 	"log"
 	"os"
+	"reflect"
+	"strings"
 	"time"
 )
 
@@ -19,21 +21,24 @@ const (
 	fSample         = "t"
 	fRampupStrategy = "s"
 	fDoTimeout      = "timeout"
+	fDashboard      = "dashboard"
 )
 
 var (
-	oRPS            = flag.Int(fRPS, 1, "target number of requests per second, must be greater than zero")
-	oAttackTime     = flag.Int(fAttackTime, 60, "duration of the attack in seconds")
-	oRampupTime     = flag.Int(fRampupTime, 10, "ramp up time in seconds")
+	oRPS            = newRateFlag(fRPS, 1, "target number of requests per second or rate like 300/m, must be a whole number of requests per second greater than zero")
+	oAttackTime     = newSecondsFlag(fAttackTime, 60, "duration of the attack in seconds or Go duration like 4h")
+	oRampupTime     = newSecondsFlag(fRampupTime, 10, "ramp up time in seconds or Go duration like 5m")
 	oMaxAttackers   = flag.Int(fMaxAttackers, 10, "maximum concurrent attackers")
 	oOutput         = flag.String(fOutput, "", "output file to write the metrics per sample request index (use stdout if empty)")
 	oVerbose        = flag.Bool(fVerbose, false, "produce more verbose logging")
 	oSample         = flag.Int(fSample, 0, "test your attack implementation with a number of sample calls. Your program exits after this")
 	oRampupStrategy = flag.String(fRampupStrategy, defaultRampupStrategy, "set the rampup strategy, possible values are {linear,exp2}")
-	oDoTimeout      = flag.Int(fDoTimeout, 5, "timeout in seconds for each attack call")
+	oDoTimeout      = newSecondsFlag(fDoTimeout, 5, "timeout in seconds or Go duration for each attack call")
+	oDashboard      = flag.Bool(fDashboard, false, "show live terminal dashboard during suite run instead of verbose logs")
 )
 
-var fullAttackStartedAt time.Time
+// suiteOverrides are command line overrides of suite config, they are applied again when config is reloaded
+var suiteOverrides SuiteOverrides
 
 type SuiteConfig struct {
 	RootKeys      string   `mapstructure:"rootkeys"`
@@ -42,6 +47,13 @@ type SuiteConfig struct {
 	HttpTimeout   int      `mapstructure:"http_timeout"`
 	Handles       []Config `mapstructure:"handles"`
 	ExecutionMode string   `mapstructure:"execution_mode"`
+	Dashboard     bool     `mapstructure:"dashboard"`
+	// Environment baselines are kept for, LOADGEN_ENV overrides it
+	Environment string `mapstructure:"environment"`
+	// TagDimensions are default tag dimensions for handles without their own
+	TagDimensions []string `mapstructure:"tag_dimensions"`
+	// Thresholds are default degradation thresholds for handles without their own
+	Thresholds []Threshold `mapstructure:"thresholds"`
 }
 
 // Config holds settings for a Runner.
@@ -62,6 +74,14 @@ type Config struct {
 	WriteToCsvName  string            `mapstructure:"csv_write"`
 	HandleParams    map[string]string `mapstructure:"handle_params"`
 	SequenceNum     int               `mapstructure:"sequence_num"`
+	// DependsOn are handles which must finish before the handle starts in dag execution mode
+	DependsOn []string `mapstructure:"depends_on"`
+	// StartOffsetSec delays start of the handle after its dependencies are finished in dag execution mode
+	StartOffsetSec int `mapstructure:"start_offset_sec"`
+	// TagDimensions are DoResult tag keys metrics are broken down by
+	TagDimensions []string `mapstructure:"tag_dimensions"`
+	// Thresholds are degradation limits of handle labels metrics
+	Thresholds []Threshold `mapstructure:"thresholds"`
 }
 
 // Validate checks all settings and returns a list of strings with problems.
@@ -81,6 +101,10 @@ func (c Config) Validate() (list []string) {
 	if c.DoTimeoutSec <= 0 {
 		list = append(list, "please set the Do() timeout to a positive maximum number of seconds")
 	}
+	if c.StartOffsetSec < 0 {
+		list = append(list, "please set the start offset to zero or a positive number of seconds")
+	}
+	list = append(list, ValidateThresholds(c.Thresholds)...)
 	return
 }
 
@@ -150,18 +174,44 @@ func applyFlagOverrides(c *Config) {
 	})
 }
 
-// LoadAttackProfileCfg loads yaml load profile config
+// LoadAttackProfileCfg loads yaml load profile config, -set and -rps-scale overrides are applied to it
 func LoadAttackProfileCfg() *SuiteConfig {
 	cfgPath := flag.String("config", "", "load attack profile config filepath")
+	suiteOverrides.RegisterFlags(flag.CommandLine)
 	flag.Parse()
-	viper.SetConfigFile(*cfgPath)
-	err := viper.ReadInConfig()
-	if err != nil {
-		log.Fatalf("Failed to readIn viper: %s\n", err)
+	if problems := ValidateOverriddenSuiteConfigFile(*cfgPath, suiteOverrides); len(problems) > 0 {
+		for _, p := range problems {
+			log.Printf("config error: %s", p)
+		}
+		os.Exit(2)
 	}
 	var suiteCfg *SuiteConfig
-	if err := viper.Unmarshal(&suiteCfg); err != nil {
+	if err := viper.Unmarshal(&suiteCfg, viper.DecodeHook(configDecodeHook)); err != nil {
 		log.Fatalf("failed to unmarshal suite config: %s\n", err)
 	}
 	return suiteCfg
 }
+
+// configDecodeHook keeps viper default decoding of durations and comma separated lists,
+// decodes string thresholds as SLO assertions and duration and rate strings of handle and suite settings
+func configDecodeHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to == reflect.TypeOf(Config{}) || to == reflect.TypeOf(SuiteConfig{}) {
+		return normalizeUnits(to, data)
+	}
+	str, ok := data.(string)
+	if !ok {
+		return data, nil
+	}
+	switch {
+	case to == reflect.TypeOf(Threshold{}):
+		return Threshold{Expr: str}, nil
+	case to == reflect.TypeOf(time.Duration(0)):
+		return time.ParseDuration(str)
+	case to.Kind() == reflect.Slice:
+		if str == "" {
+			return []string{}, nil
+		}
+		return strings.Split(str, ","), nil
+	}
+	return data, nil
+}
@@ -0,0 +1,11 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package loadgen
+
+import "time"
+
+// processCPUTime is not supported on this platform
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
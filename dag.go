@@ -0,0 +1,158 @@
+package loadgen
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dependencyProblems returns problems of depends_on for every handle: unknown and duplicate dependencies and cycles
+func dependencyProblems(handles []Config) [][]string {
+	problems := make([][]string, len(handles))
+	byName := map[string]int{}
+	for i, h := range handles {
+		byName[h.HandleName] = i
+	}
+	for i, h := range handles {
+		seen := map[string]bool{}
+		for _, d := range h.DependsOn {
+			if _, ok := byName[d]; !ok {
+				problems[i] = append(problems[i], fmt.Sprintf("depends on unknown handle [%s]", d))
+			}
+			if d == h.HandleName {
+				problems[i] = append(problems[i], "depends on itself")
+			}
+			if seen[d] {
+				problems[i] = append(problems[i], fmt.Sprintf("depends on [%s] twice", d))
+			}
+			seen[d] = true
+		}
+	}
+	// cycles are searched by depth first walk, back edge closes a cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(handles))
+	var path []string
+	var walk func(i int)
+	walk = func(i int) {
+		state[i] = visiting
+		path = append(path, handles[i].HandleName)
+		for _, d := range handles[i].DependsOn {
+			j, ok := byName[d]
+			if !ok || j == i {
+				continue
+			}
+			switch state[j] {
+			case unvisited:
+				walk(j)
+			case visiting:
+				var cycle []string
+				for k := len(path) - 1; k >= 0; k-- {
+					cycle = append([]string{path[k]}, cycle...)
+					if path[k] == d {
+						break
+					}
+				}
+				problems[i] = append(problems[i], fmt.Sprintf("dependency cycle: %s -> %s", strings.Join(cycle, " -> "), d))
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+	}
+	for i := range handles {
+		if state[i] == unvisited {
+			walk(i)
+		}
+	}
+	return problems
+}
+
+// runGraph starts every handle when all handles it depends on are finished and its start offset is passed,
+// independent handles run in parallel, handles with failed dependencies are skipped
+func (m *LoadManager) runGraph() {
+	configs := make([]Config, len(m.Groups))
+	for i, r := range m.Groups {
+		configs[i] = r.config
+	}
+	for i, problems := range dependencyProblems(configs) {
+		if len(problems) > 0 {
+			m.Fatalf("[ %s ] %s", configs[i].HandleName, strings.Join(problems, ", "))
+		}
+	}
+	done := make(map[string]chan struct{}, len(m.Groups))
+	for _, r := range m.Groups {
+		done[r.name] = make(chan struct{})
+	}
+	var wg sync.WaitGroup
+	wg.Add(len(m.Groups))
+	for _, r := range m.Groups {
+		go func(r *Runner) {
+			defer wg.Done()
+			defer close(done[r.name])
+			for _, d := range r.config.DependsOn {
+				<-done[d]
+			}
+			if reason := m.dependencyFailure(r.config.DependsOn); reason != "" {
+				m.skipHandle(r, reason)
+				return
+			}
+			if offset := time.Duration(r.config.StartOffsetSec) * time.Second; offset > 0 {
+				log.Printf("[ %s ] starting in %s", r.name, offset)
+				select {
+				case <-time.After(offset):
+				case <-m.abort:
+				}
+			}
+			if m.IsAborted() {
+				m.skipHandle(r, "suite is aborted")
+				return
+			}
+			r.SetupHandleStore(m)
+			r.Run(nil, m)
+			// data written by handle is read by handles depending on it
+			m.CsvMu.Lock()
+			if s, ok := m.CsvStore[r.config.WriteToCsvName]; ok {
+				s.Flush()
+			}
+			m.CsvMu.Unlock()
+		}(r)
+	}
+	wg.Wait()
+}
+
+// dependencyFailure returns reason to skip handle when any of handles it depends on failed or the suite is aborted
+func (m *LoadManager) dependencyFailure(dependsOn []string) string {
+	if m.IsAborted() {
+		return "suite is aborted"
+	}
+	m.CsvMu.Lock()
+	defer m.CsvMu.Unlock()
+	for _, d := range dependsOn {
+		if r, ok := m.Reports[d]; !ok || r.Failed {
+			return fmt.Sprintf("dependency [%s] failed", d)
+		}
+	}
+	return ""
+}
+
+// skipHandle stores failed report of handle which was not run
+func (m *LoadManager) skipHandle(r *Runner, reason string) {
+	log.Printf("[ %s ] handle skipped: %s", r.name, reason)
+	r.live.setPhase(PhaseDone)
+	m.CsvMu.Lock()
+	defer m.CsvMu.Unlock()
+	m.Reports[r.name] = &RunReport{
+		Configuration: r.config,
+		RunError:      "skipped: " + reason,
+		Metrics:       map[string]*Metrics{},
+		Failed:        true,
+		Output:        map[string]interface{}{},
+		Provenance:    m.Provenance,
+	}
+	m.markFailed(false)
+}
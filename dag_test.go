@@ -0,0 +1,126 @@
+package loadgen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestDependencyProblems(t *testing.T) {
+	handles := []Config{
+		{HandleName: "prepare"},
+		{HandleName: "transfer", DependsOn: []string{"prepare", "verify"}},
+		{HandleName: "balance", DependsOn: []string{"prepare", "deposit", "balance"}},
+		{HandleName: "verify", DependsOn: []string{"transfer"}},
+	}
+	problems := dependencyProblems(handles)
+	if got, want := len(problems[0]), 0; got != want {
+		t.Errorf("got %v want %v", problems[0], want)
+	}
+	if got, want := strings.Join(problems[2], ", "), "depends on unknown handle [deposit], depends on itself"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := strings.Join(problems[3], ", "), "dependency cycle: transfer -> verify -> transfer"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestValidateDependencies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer viper.Reset()
+
+	p := writeTestConfig(t, dir, "flow.yaml", `execution_mode: dag
+defaults:
+  rps: 10
+  attack_time: 1m
+  ramp_up: 10s
+  max_attackers: 10
+  do_timeout: 5s
+handles:
+  - name: prepare
+  - name: transfer
+    depends_on: [prepare, verify]
+  - name: verify
+    depends_on: [transfer]
+    start_offset: 30s
+`)
+	var got []string
+	for _, p := range ValidateSuiteConfigFile(p) {
+		got = append(got, strings.TrimPrefix(p.String(), dir+string(filepath.Separator)))
+	}
+	want := []string{`flow.yaml:13: handles[2].depends_on: dependency cycle: transfer -> verify -> transfer`}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %v want %v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	p = writeTestConfig(t, dir, "parallel.yaml", `execution_mode: parallel
+handles:
+  - name: prepare
+    rps: 10
+    attack_time_sec: 60
+    ramp_up_sec: 10
+    max_attackers: 10
+    do_timeout_sec: 5
+    start_offset_sec: 5
+`)
+	problems := ValidateSuiteConfigFile(p)
+	if got, want := len(problems), 1; got != want {
+		t.Fatalf("got %v want %v", problems, want)
+	}
+	if !strings.Contains(problems[0].Message, "require execution_mode dag") {
+		t.Errorf("unexpected problem: %s", problems[0])
+	}
+}
+
+func TestRunGraph(t *testing.T) {
+	lm := NewLoadManager()
+	c := Config{RPS: 5, AttackTimeSec: 2, RampUpTimeSec: 1, MaxAttackers: 2, DoTimeoutSec: 1}
+	prepare, transfer, balance := c, c, c
+	prepare.HandleName = "prepare"
+	transfer.HandleName, transfer.DependsOn = "transfer", []string{"prepare"}
+	balance.HandleName, balance.DependsOn, balance.StartOffsetSec = "balance", []string{"prepare"}, 1
+	for _, h := range []Config{balance, transfer, prepare} {
+		lm.Groups = append(lm.Groups, NewRunner(h.HandleName, lm, &attackMock{}, h))
+	}
+	lm.runGraph()
+	p, tr, b := lm.Reports["prepare"], lm.Reports["transfer"], lm.Reports["balance"]
+	if tr.StartedAt.Before(p.FinishedAt) || b.StartedAt.Before(p.FinishedAt) {
+		t.Error("dependent handles started before dependency finished")
+	}
+	if b.StartedAt.Sub(tr.StartedAt) < 900*time.Millisecond {
+		t.Errorf("expected balance to start with offset: %s %s", tr.StartedAt, b.StartedAt)
+	}
+
+	p.Failed = true
+	if got, want := lm.dependencyFailure(transfer.DependsOn), "dependency [prepare] failed"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestSkipHandleWithGuardBreach(t *testing.T) {
+	lm := NewLoadManager()
+	c := Config{HandleName: "transfer", RPS: 5, AttackTimeSec: 2, RampUpTimeSec: 1, MaxAttackers: 2, DoTimeoutSec: 1}
+	r := NewRunner(c.HandleName, lm, &attackMock{}, c)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lm.AddGuardBreach(GuardBreach{Guard: "cpu", Action: GuardActionAbort, At: time.Now()})
+	}()
+	lm.skipHandle(r, "suite is aborted")
+	<-done
+	if !lm.Failed || !lm.Aborted {
+		t.Errorf("expected failed and aborted suite: %v %v", lm.Failed, lm.Aborted)
+	}
+	if got, want := lm.Reports["transfer"].RunError, "skipped: suite is aborted"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,160 @@
+package loadgen
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	DegradationMethodMannWhitney = "mann-whitney"
+	DegradationMethodP50Ratio    = "p50-ratio"
+
+	defaultPValueThreshold = 0.01
+	defaultBaselineRuns    = 5
+	// minBaselineRetention green runs are kept in baseline history, e.g. to widen the window later
+	minBaselineRetention = 100
+)
+
+// DegradationCheck is a result of handle latency comparison to last successful runs
+type DegradationCheck struct {
+	// Method is mann-whitney when all reports have latency histograms, p50-ratio otherwise
+	Method string `json:"method"`
+	// BaselineRuns timestamps of successful runs used as baseline
+	BaselineRuns []int64       `json:"baselineRuns"`
+	CurrentP50   time.Duration `json:"currentP50"`
+	BaselineP50  time.Duration `json:"baselineP50"`
+	// Ratio is current p50 to baseline p50 ratio
+	Ratio     float64 `json:"ratio"`
+	Threshold float64 `json:"threshold"`
+	// U is Mann-Whitney statistic of current run
+	U float64 `json:"u,omitempty"`
+	// PValue is the probability to see such shift when latencies did not grow
+	PValue *float64 `json:"pValue,omitempty"`
+	Alpha  float64  `json:"alpha,omitempty"`
+	// Degraded is set when p-value is below alpha and ratio reached threshold
+	Degraded bool `json:"degraded"`
+}
+
+func (c *DegradationCheck) String() string {
+	s := fmt.Sprintf("%s: current p50 %s, baseline p50 %s of %d runs, ratio %.3f", c.Method, c.CurrentP50, c.BaselineP50, len(c.BaselineRuns), c.Ratio)
+	if c.PValue != nil {
+		s += fmt.Sprintf(", p-value %.4g", *c.PValue)
+	}
+	return s
+}
+
+func hasHistogram(m *Metrics) bool {
+	return m.Histogram != nil && len(m.Histogram.Buckets) > 0
+}
+
+// median returns median of values, zero for no values
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func latencyRatio(current, baseline time.Duration) float64 {
+	if baseline <= 0 {
+		return 0
+	}
+	return float64(current) / float64(baseline)
+}
+
+// NewDegradationCheck compares current latency distribution to merged distribution of baseline runs
+func NewDegradationCheck(current *Metrics, baselines []*Metrics, threshold float64, alpha float64) *DegradationCheck {
+	lc := newLatencyCheck(0.5, current, baselines, alpha)
+	return &DegradationCheck{
+		Method:      lc.method,
+		CurrentP50:  lc.current,
+		BaselineP50: lc.baseline,
+		Ratio:       lc.ratio,
+		Threshold:   threshold,
+		U:           lc.u,
+		PValue:      lc.pValue,
+		Alpha:       lc.alpha,
+		Degraded:    lc.degraded(threshold),
+	}
+}
+
+// latencyCheck compares one latency quantile of current run to baseline runs
+type latencyCheck struct {
+	method            string
+	current, baseline time.Duration
+	ratio             float64
+	u                 float64
+	pValue            *float64
+	alpha             float64
+}
+
+// degraded reports if ratio reached threshold and, for histograms, the shift is significant
+func (c latencyCheck) degraded(threshold float64) bool {
+	if c.pValue != nil {
+		return *c.pValue < c.alpha && c.ratio >= threshold
+	}
+	return c.baseline > 0 && c.ratio >= threshold
+}
+
+// latencyQuantile returns latency metric for quantile q, q = 1 is max latency
+func latencyQuantile(m *Metrics, q float64) time.Duration {
+	switch q {
+	case 0.5:
+		return m.Latencies.P50
+	case 0.95:
+		return m.Latencies.P95
+	case 0.99:
+		return m.Latencies.P99
+	case 1:
+		return m.Latencies.Max
+	}
+	if hasHistogram(m) {
+		return m.Histogram.Quantile(q)
+	}
+	return 0
+}
+
+func newLatencyCheck(q float64, current *Metrics, baselines []*Metrics, alpha float64) latencyCheck {
+	var c latencyCheck
+	histograms := hasHistogram(current)
+	for _, b := range baselines {
+		histograms = histograms && hasHistogram(b)
+	}
+	if histograms && len(baselines) > 0 {
+		hs := make([]*LatencyHistogram, 0, len(baselines))
+		for _, b := range baselines {
+			hs = append(hs, b.Histogram)
+		}
+		baseline := MergeHistograms(hs...)
+		c.method = DegradationMethodMannWhitney
+		c.alpha = alpha
+		c.current = current.Histogram.Quantile(q)
+		values := make([]float64, 0, len(hs))
+		for _, h := range hs {
+			values = append(values, float64(h.Quantile(q)))
+		}
+		c.baseline = time.Duration(median(values))
+		c.ratio = latencyRatio(c.current, c.baseline)
+		u, p := MannWhitneyGreater(baseline, current.Histogram)
+		c.u = u
+		c.pValue = &p
+		return c
+	}
+	// reports written before histograms were stored
+	c.method = DegradationMethodP50Ratio
+	c.current = latencyQuantile(current, q)
+	values := make([]float64, 0, len(baselines))
+	for _, b := range baselines {
+		values = append(values, float64(latencyQuantile(b, q)))
+	}
+	c.baseline = time.Duration(median(values))
+	c.ratio = latencyRatio(c.current, c.baseline)
+	return c
+}
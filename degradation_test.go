@@ -0,0 +1,123 @@
+package loadgen
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func testLatencyMetrics(seed int64, median time.Duration, n int) *Metrics {
+	rnd := rand.New(rand.NewSource(seed))
+	m := new(Metrics)
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		elapsed := time.Duration(float64(median) * (0.8 + 0.4*rnd.Float64()))
+		m.add(result{begin: now, end: now.Add(elapsed), elapsed: elapsed})
+	}
+	m.updateLatencies()
+	return m
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	m := testLatencyMetrics(1, 10*time.Millisecond, 1000)
+	p50 := m.Histogram.Quantile(0.5)
+	if p50 < 9*time.Millisecond || p50 > 11*time.Millisecond {
+		t.Errorf("got %v want about 10ms", p50)
+	}
+	if got, want := m.Histogram.Total(), uint64(1000); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestDegradationOfSubMillisecondHandle(t *testing.T) {
+	baselines := []*Metrics{
+		testLatencyMetrics(1, 300*time.Microsecond, 500),
+		testLatencyMetrics(2, 300*time.Microsecond, 500),
+	}
+	slower := testLatencyMetrics(3, 600*time.Microsecond, 500)
+	c := NewDegradationCheck(slower, baselines, 1.2, 0.01)
+	if got, want := c.Method, DegradationMethodMannWhitney; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if !c.Degraded {
+		t.Errorf("expected degradation: %s", c)
+	}
+	same := testLatencyMetrics(4, 300*time.Microsecond, 500)
+	c = NewDegradationCheck(same, baselines, 1.2, 0.01)
+	if c.Degraded {
+		t.Errorf("expected no degradation: %s", c)
+	}
+	if *c.PValue < 0.01 {
+		t.Errorf("expected big p-value: %s", c)
+	}
+}
+
+func TestDegradationWithoutHistograms(t *testing.T) {
+	baseline := &Metrics{Latencies: LatencyMetrics{P50: 10 * time.Millisecond}}
+	current := &Metrics{Latencies: LatencyMetrics{P50: 15 * time.Millisecond}}
+	c := NewDegradationCheck(current, []*Metrics{baseline}, 1.2, 0.01)
+	if got, want := c.Method, DegradationMethodP50Ratio; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if !c.Degraded || c.PValue != nil {
+		t.Errorf("expected ratio degradation: %s", c)
+	}
+}
+
+func TestSuccessHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lm := NewLoadManager()
+	lm.ReportDir = dir
+	for _, ts := range []int64{100, 200, 300} {
+		writeTestReport(t, dir, "transfer", ts, testRunReport(time.Millisecond))
+		lm.WriteLastSuccess("transfer", ts)
+	}
+	reports, history, err := lm.LastSuccessReportsForHandle("transfer", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(reports), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := history[0], int64(200); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	last, err := lm.LastSuccessReportForHandle("transfer")
+	if err != nil || last == nil {
+		t.Fatal("expected last success report")
+	}
+}
+
+func TestCheckDegradationWithAssertionsOnly(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("checks.handle_threshold_percent", 1.2)
+	dir, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lm := NewLoadManager()
+	lm.ReportDir = dir
+	for _, ts := range []int64{100, 200} {
+		writeTestReport(t, dir, "transfer", ts, testRunReport(time.Millisecond))
+		lm.WriteLastSuccess("transfer", ts)
+	}
+	current := testRunReport(10 * time.Millisecond)
+	current.Configuration.Thresholds = []Threshold{{Expr: "p99 < 300ms"}}
+	lm.Reports["transfer"] = current
+	lm.CheckDegradation()
+	if current.DegradationCheck == nil {
+		t.Fatal("expected default p50 check of handle with assertions only")
+	}
+	if !current.Degradation {
+		t.Errorf("expected degradation, got violations %v", current.ThresholdViolations)
+	}
+}
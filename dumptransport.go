@@ -94,6 +94,8 @@ func NewLoggingHTTPClient(debug bool, transportTimeout int) *http.Client {
 	} else {
 		transport = http.DefaultTransport
 	}
+	// client may be created before InitTracing, requests are traced when made in traced Do
+	transport = &TracingTransport{transport}
 	cookieJar, _ := cookiejar.New(nil)
 	return &http.Client{
 		Transport: transport,
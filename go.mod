@@ -4,9 +4,11 @@ go 1.12
 
 require (
 	github.com/cyberdelia/go-metrics-graphite v0.0.0-20161219230853-39f87cc3b432
+	github.com/fsnotify/fsnotify v1.4.7
 	github.com/insolar/x-crypto v0.0.0-20191031140942-75fab8a325f6
 	github.com/rcrowley/go-metrics v0.0.0-20190826022208-cac0b30c2563
 	github.com/spf13/viper v1.6.1
 	github.com/streadway/quantile v0.0.0-20150917103942-b0c588724d25
 	go.uber.org/ratelimit v0.1.0
+	gopkg.in/yaml.v2 v2.2.4
 )
@@ -24,10 +24,13 @@ var (
 	projectMetricPrefix      = "observer"
 	percentilesScaleFactor   = "0.000001"
 	alias                    = "%s-%s"
-	percentileTargetTemplate = "alias(scale(%s.%s-timer.%s-percentile, %s), '%s')"
-	rpsTargetTemplate        = "alias(perSecond(%s.%s-%s.count_ps), '%s')"
-	goroutinesTotalTemplate  = "%s.goroutines-goroutinesCount.value"
-	PanelID                  = 0
+	percentileTargetTemplate = "aliasSub(scale(%s.*.%s-timer.%s-percentile, %s), '^.*\\(%s\\.([^.]+)\\..*$', '\\1-%s')"
+	rpsTargetTemplate        = "aliasSub(perSecond(%s.*.%s-%s.count_ps), '^.*\\(%s\\.([^.]+)\\..*$', '\\1-%s')"
+	// tagged series are <prefix>.<handle>.tags.<dimension>.<value>.<label>-timer, aliased as <handle>-<value>-<label>-<percentile>
+	tagPercentileTargetTemplate = "aliasSub(scale(%s.*.tags.%s.*.%s-timer.%s-percentile, %s), '^.*\\(%s\\.([^.]+)\\.tags\\.[^.]+\\.([^.]+)\\..*$', '\\1-\\2-%s')"
+	tagRPSTargetTemplate        = "aliasSub(perSecond(%s.*.tags.%s.*.%s-%s.count_ps), '^.*\\(%s\\.([^.]+)\\.tags\\.[^.]+\\.([^.]+)\\..*$', '\\1-\\2-%s')"
+	goroutinesTotalTemplate     = "aliasByNode(%s.*.goroutines-goroutinesCount.value, 1)"
+	PanelID                     = 0
 )
 
 type Inputs []struct {
@@ -190,6 +193,7 @@ func GeneratePercentileTargets(labels []string) []Target {
 				label,
 				percentile,
 				percentilesScaleFactor,
+				projectMetricPrefix,
 				title,
 			)
 			targets = append(targets, Target{
@@ -210,6 +214,7 @@ func GenerateRPSTargets(labels []string) []Target {
 				projectMetricPrefix,
 				label,
 				suffix,
+				projectMetricPrefix,
 				title,
 			)
 			targets = append(targets, Target{
@@ -220,6 +225,49 @@ func GenerateRPSTargets(labels []string) []Target {
 	return targets
 }
 
+// GenerateTagPercentileTargets returns percentile targets of labels broken down by tag dimension
+func GenerateTagPercentileTargets(labels []string, dimension string) []Target {
+	targets := make([]Target, 0)
+	for _, label := range labels {
+		for _, percentile := range percentiles {
+			targets = append(targets, Target{
+				Target: fmt.Sprintf(
+					tagPercentileTargetTemplate,
+					projectMetricPrefix,
+					graphiteNode(dimension),
+					label,
+					percentile,
+					percentilesScaleFactor,
+					projectMetricPrefix,
+					fmt.Sprintf(alias, label, percentile),
+				),
+			})
+		}
+	}
+	return targets
+}
+
+// GenerateTagRPSTargets returns rps targets of labels broken down by tag dimension
+func GenerateTagRPSTargets(labels []string, dimension string) []Target {
+	targets := make([]Target, 0)
+	for _, label := range labels {
+		for _, suffix := range rpsLabelSuffixes {
+			targets = append(targets, Target{
+				Target: fmt.Sprintf(
+					tagRPSTargetTemplate,
+					projectMetricPrefix,
+					graphiteNode(dimension),
+					label,
+					suffix,
+					projectMetricPrefix,
+					fmt.Sprintf(alias, label, suffix),
+				),
+			})
+		}
+	}
+	return targets
+}
+
 func GenerateRPSPanel(title string, targets []Target) Panel {
 	PanelID += 1
 	return Panel{
@@ -396,7 +444,8 @@ func GenerateRow(panel Panel) Row {
 	}
 }
 
-func GenerateRows(labels []string) []Row {
+// GenerateRows generates dashboard rows of labels, percentiles and rps of every tag dimension have their own rows
+func GenerateRows(labels []string, dimensions ...string) []Row {
 	infoTargets := GenerateGoroutinesTotalTarget()
 	percTargets := GeneratePercentileTargets(labels)
 	rpsTargets := GenerateRPSTargets(labels)
@@ -408,12 +457,20 @@ func GenerateRows(labels []string) []Row {
 	rpsRow := GenerateRow(rpsPanel)
 
 	rows := make([]Row, 0)
-	rows = append(rows, percRow, rpsRow, infoRow)
+	rows = append(rows, percRow, rpsRow)
+	for _, dim := range dimensions {
+		rows = append(rows,
+			GenerateRow(GeneratePercentilePanel(fmt.Sprintf("Percentiles (50,95,99) by %s", dim), GenerateTagPercentileTargets(labels, dim))),
+			GenerateRow(GenerateRPSPanel(fmt.Sprintf("RPS (Total+Errors) by %s", dim), GenerateTagRPSTargets(labels, dim))),
+		)
+	}
+	rows = append(rows, infoRow)
 	return rows
 }
 
-func GrafanaDashboard(title string, labels []string) Dashboard {
-	rows := GenerateRows(labels)
+// GrafanaDashboard generates dashboard of labels, metrics broken down by tag dimensions are shown in their own rows
+func GrafanaDashboard(title string, labels []string, dimensions ...string) Dashboard {
+	rows := GenerateRows(labels, dimensions...)
 	return Dashboard{
 		Inputs: Inputs{
 			{
@@ -497,7 +554,7 @@ func GrafanaDashboard(title string, labels []string) Dashboard {
 
 func GenerateGrafanaDashboard() {
 	title := viper.GetString("graphite.loadGeneratorPrefix")
-	dashboard, err := json.Marshal(GrafanaDashboard(title, ParseLabels()))
+	dashboard, err := json.Marshal(GrafanaDashboard(title, ParseLabels(), ConfiguredTagDimensions()...))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -507,6 +564,29 @@ func GenerateGrafanaDashboard() {
 	}
 }
 
+// ConfiguredTagDimensions returns tag dimensions of suite config and its handles
+func ConfiguredTagDimensions() []string {
+	var suiteCfg SuiteConfig
+	if err := viper.Unmarshal(&suiteCfg, viper.DecodeHook(configDecodeHook)); err != nil {
+		log.Printf("failed to read tag dimensions of suite config: %s\n", err)
+		return nil
+	}
+	var dims []string
+	for _, c := range suiteCfg.HandleConfigs() {
+		for _, d := range c.TagDimensions {
+			if !containsString(dims, d) {
+				dims = append(dims, d)
+			}
+		}
+	}
+	for _, d := range suiteCfg.TagDimensions {
+		if !containsString(dims, d) {
+			dims = append(dims, d)
+		}
+	}
+	return dims
+}
+
 func ParseLabels() []string {
 	fset := token.NewFileSet()
 	fpath := fmt.Sprintf(labelsPath, viper.GetString("load_scripts_dir"))
@@ -40,7 +40,7 @@ func UploadGrafanaDashboard() {
 	title := viper.GetString("graphite.loadGeneratorPrefix")
 	url := viper.GetString("grafana.url") + "/api/dashboards/import"
 	log.Printf("importing grafana dashboard to %s", url)
-	dashboard := GrafanaDashboard(title, ParseLabels())
+	dashboard := GrafanaDashboard(title, ParseLabels(), ConfiguredTagDimensions()...)
 	payload := ImportPayload{
 		Dashboard: dashboard,
 		Overwrite: true,
@@ -0,0 +1,293 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	GuardActionAbort = "abort"
+	GuardActionFail  = "fail"
+
+	defaultGuardIntervalSec = 5
+)
+
+// Guard is a PromQL query checked against threshold during the run
+type Guard struct {
+	Name  string `mapstructure:"name" json:"name"`
+	Query string `mapstructure:"query" json:"query"`
+	// Threshold is breached when any sample of the query result is above it, or below it when Below is set
+	Threshold float64 `mapstructure:"threshold" json:"threshold"`
+	Below     bool    `mapstructure:"below" json:"below"`
+	// Action is one of abort or fail, abort stops all handles, fail only marks suite failed
+	Action string `mapstructure:"action" json:"action"`
+}
+
+// GuardBreach records a guard threshold crossing, sustained breach is one record from At to LastSeen
+type GuardBreach struct {
+	Guard     string  `json:"guard"`
+	Query     string  `json:"query"`
+	Threshold float64 `json:"threshold"`
+	// Value is the first breaching value, Peak is the worst one while guard stayed breached
+	Value  float64           `json:"value"`
+	Peak   float64           `json:"peak"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Action string            `json:"action"`
+	// At is when guard was breached first, LastSeen is the last check it was still breached
+	At       time.Time `json:"at"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+func (b GuardBreach) String() string {
+	return fmt.Sprintf("guard [%s] breached: value %f, peak %f, threshold %f, labels %v", b.Guard, b.Value, b.Peak, b.Threshold, b.Labels)
+}
+
+func (g Guard) breached(v float64) bool {
+	if g.Below {
+		return v < g.Threshold
+	}
+	return v > g.Threshold
+}
+
+// worse returns the value breaching threshold more
+func (g Guard) worse(a, b float64) float64 {
+	if g.Below == (a < b) {
+		return a
+	}
+	return b
+}
+
+func (g Guard) action() string {
+	if g.Action == "" {
+		return GuardActionAbort
+	}
+	return g.Action
+}
+
+// GuardsFromConfig reads guards from prometheus.guards section,
+// legacy prometheus.pulse_lag_threshold and prometheus.opened_requests_threshold are used
+// with prometheus.pulse_lag_query and prometheus.opened_requests_query
+func GuardsFromConfig() []Guard {
+	var guards []Guard
+	if err := viper.UnmarshalKey("prometheus.guards", &guards); err != nil {
+		log.Fatalf("failed to unmarshal prometheus guards: %s\n", err)
+	}
+	legacy := []struct {
+		name, thresholdKey, queryKey string
+	}{
+		{"pulse_lag", "prometheus.pulse_lag_threshold", "prometheus.pulse_lag_query"},
+		{"opened_requests", "prometheus.opened_requests_threshold", "prometheus.opened_requests_query"},
+	}
+	for _, l := range legacy {
+		if !viper.IsSet(l.thresholdKey) {
+			continue
+		}
+		query := viper.GetString(l.queryKey)
+		if query == "" {
+			log.Printf("[ guards ] %s is set, but %s is empty, guard is disabled\n", l.thresholdKey, l.queryKey)
+			continue
+		}
+		guards = append(guards, Guard{
+			Name:      l.name,
+			Query:     query,
+			Threshold: viper.GetFloat64(l.thresholdKey),
+			Action:    GuardActionAbort,
+		})
+	}
+	return guards
+}
+
+// Validate checks guard settings and returns a list of strings with problems.
+func (g Guard) Validate() (list []string) {
+	if g.Name == "" {
+		list = append(list, "please set guard name")
+	}
+	if g.Query == "" {
+		list = append(list, fmt.Sprintf("please set query for guard [%s]", g.Name))
+	}
+	if a := g.action(); a != GuardActionAbort && a != GuardActionFail {
+		list = append(list, fmt.Sprintf("unknown action [%s] for guard [%s], possible values are {abort,fail}", a, g.Name))
+	}
+	return
+}
+
+// GuardMonitor periodically runs guard queries against prometheus HTTP API
+type GuardMonitor struct {
+	m        *LoadManager
+	url      string
+	guards   []Guard
+	interval time.Duration
+	client   *http.Client
+	quit     chan struct{}
+	wg       sync.WaitGroup
+	// active are breaches of guards which are still breached
+	active map[string]GuardBreach
+}
+
+// NewGuardMonitor creates monitor from config, returns nil if no guards are configured
+func NewGuardMonitor(m *LoadManager) *GuardMonitor {
+	guards := GuardsFromConfig()
+	if len(guards) == 0 {
+		return nil
+	}
+	promURL := viper.GetString("prometheus.url")
+	if promURL == "" {
+		log.Fatal("prometheus guards are configured, but prometheus.url is empty")
+	}
+	for _, g := range guards {
+		if msg := g.Validate(); len(msg) > 0 {
+			log.Fatalf("guard configuration errors: %s", strings.Join(msg, ", "))
+		}
+	}
+	interval := viper.GetInt("prometheus.pulse_diff_check_interval_sec")
+	if interval <= 0 {
+		interval = defaultGuardIntervalSec
+	}
+	return &GuardMonitor{
+		m:        m,
+		url:      strings.TrimRight(promURL, "/") + "/api/v1/query",
+		guards:   guards,
+		interval: time.Duration(interval) * time.Second,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start starts checking guards in background
+func (g *GuardMonitor) Start() {
+	if g == nil {
+		return
+	}
+	log.Printf("[ guards ] checking %d guards every %s\n", len(g.guards), g.interval)
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		ticker := time.NewTicker(g.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				g.check()
+			case <-g.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops checking guards
+func (g *GuardMonitor) Stop() {
+	if g == nil {
+		return
+	}
+	close(g.quit)
+	g.wg.Wait()
+}
+
+// check records breach when guard gets breached, extends it while guard stays breached
+// and clears it when guard recovers
+func (g *GuardMonitor) check() {
+	if g.active == nil {
+		g.active = map[string]GuardBreach{}
+	}
+	for _, guard := range g.guards {
+		samples, err := g.query(guard.Query)
+		if err != nil {
+			log.Printf("[ guards ] query for guard [%s] failed: %v\n", guard.Name, err)
+			continue
+		}
+		var breaching *promSample
+		for i, s := range samples {
+			if guard.breached(s.value) && (breaching == nil || guard.worse(s.value, breaching.value) == s.value) {
+				breaching = &samples[i]
+			}
+		}
+		b, active := g.active[guard.Name]
+		now := time.Now()
+		switch {
+		case breaching == nil && active:
+			log.Printf("[ guards ] guard [%s] recovered, peak %f\n", guard.Name, b.Peak)
+			delete(g.active, guard.Name)
+		case breaching != nil && active:
+			b.LastSeen = now
+			b.Peak = guard.worse(b.Peak, breaching.value)
+			g.active[guard.Name] = b
+			g.m.updateGuardBreach(b)
+		case breaching != nil:
+			b = GuardBreach{
+				Guard:     guard.Name,
+				Query:     guard.Query,
+				Threshold: guard.Threshold,
+				Value:     breaching.value,
+				Peak:      breaching.value,
+				Labels:    breaching.labels,
+				Action:    guard.action(),
+				At:        now,
+				LastSeen:  now,
+			}
+			g.active[guard.Name] = b
+			g.m.AddGuardBreach(b)
+		}
+	}
+}
+
+type promSample struct {
+	labels map[string]string
+	value  float64
+}
+
+type promResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// query runs instant query, only vector results are supported
+func (g *GuardMonitor) query(q string) ([]promSample, error) {
+	resp, err := g.client.Get(g.url + "?" + url.Values{"query": {q}}.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var pr promResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+		return nil, err
+	}
+	if pr.Status != "success" {
+		return nil, fmt.Errorf("prometheus responded with status [%s]: %s", pr.Status, pr.Error)
+	}
+	if pr.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("unsupported result type: %s", pr.Data.ResultType)
+	}
+	samples := make([]promSample, 0, len(pr.Data.Result))
+	for _, r := range pr.Data.Result {
+		if len(r.Value) != 2 {
+			continue
+		}
+		str, ok := r.Value[1].(string)
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, promSample{labels: r.Metric, value: v})
+	}
+	return samples, nil
+}
@@ -0,0 +1,101 @@
+package loadgen
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testPrometheus(value string) *httptest.Server {
+	return testPrometheusValues(&value)
+}
+
+// testPrometheusValues responds with current value, tests change it between checks
+func testPrometheusValues(value *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"instance":"node-1"},"value":[1577836800,"%s"]}]}}`, *value)
+	}))
+}
+
+func TestGuardBreach(t *testing.T) {
+	value := "80"
+	prom := testPrometheusValues(&value)
+	defer prom.Close()
+	lm := &LoadManager{}
+	g := &GuardMonitor{
+		m:      lm,
+		url:    prom.URL + "/api/v1/query",
+		guards: []Guard{{Name: "pulse_lag", Query: "pulse_lag", Threshold: 70, Action: GuardActionFail}},
+		client: http.DefaultClient,
+	}
+	g.check()
+	value = "95"
+	g.check()
+	value = "75"
+	g.check()
+	if got, want := len(lm.GuardBreaches), 1; got != want {
+		t.Fatalf("sustained breach is one record: got %v want %v", got, want)
+	}
+	b := lm.GuardBreaches[0]
+	if got, want := b.Labels["instance"], "node-1"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := [2]float64{b.Value, b.Peak}, [2]float64{80, 95}; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if !b.LastSeen.After(b.At) {
+		t.Error("expected last seen after first breach")
+	}
+	if !lm.Failed {
+		t.Error("expected failed suite")
+	}
+	if lm.IsAborted() {
+		t.Error("expected not aborted suite for fail action")
+	}
+
+	// recovered guard is breached again
+	value = "10"
+	g.check()
+	value = "80"
+	g.check()
+	if got, want := len(lm.GuardBreachesBetween(time.Now().Add(-time.Minute), time.Now())), 2; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := len(lm.GuardBreachesBetween(time.Now().Add(time.Minute), time.Now().Add(2*time.Minute))), 0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestGuardAbort(t *testing.T) {
+	prom := testPrometheus("80")
+	defer prom.Close()
+	lm := NewLoadManager()
+	g := &GuardMonitor{
+		m:      lm,
+		url:    prom.URL + "/api/v1/query",
+		guards: []Guard{{Name: "pulse_lag", Query: "pulse_lag", Threshold: 70, Action: GuardActionAbort}},
+		client: http.DefaultClient,
+	}
+	g.check()
+	if !lm.IsAborted() {
+		t.Error("expected aborted suite")
+	}
+}
+
+func TestGuardNotBreached(t *testing.T) {
+	prom := testPrometheus("10")
+	defer prom.Close()
+	lm := NewLoadManager()
+	g := &GuardMonitor{
+		m:      lm,
+		url:    prom.URL + "/api/v1/query",
+		guards: []Guard{{Name: "opened_requests", Query: "opened_requests", Threshold: 20}},
+		client: http.DefaultClient,
+	}
+	g.check()
+	if len(lm.GuardBreaches) != 0 || lm.Failed || lm.IsAborted() {
+		t.Error("expected no breaches")
+	}
+}
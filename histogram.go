@@ -0,0 +1,165 @@
+package loadgen
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// histogramGrowth is relative width of histogram bucket, latency resolution is 5%
+	histogramGrowth = 1.05
+	// histogramMin is upper bound of the first bucket
+	histogramMin = time.Microsecond
+)
+
+var logHistogramGrowth = math.Log(histogramGrowth)
+
+// HistogramBucket counts latencies in (previous bucket bound, UpperBound]
+type HistogramBucket struct {
+	UpperBound time.Duration `json:"le"`
+	Count      uint64        `json:"count"`
+}
+
+// LatencyHistogram is a sparse log-bucketed latency distribution, buckets are sorted by bound
+type LatencyHistogram struct {
+	Buckets []HistogramBucket `json:"buckets"`
+	counts  map[int]uint64
+}
+
+func histogramBucketIndex(d time.Duration) int {
+	if d <= histogramMin {
+		return 0
+	}
+	return int(math.Ceil(math.Log(float64(d)/float64(histogramMin)) / logHistogramGrowth))
+}
+
+func histogramBucketBound(i int) time.Duration {
+	return time.Duration(float64(histogramMin) * math.Pow(histogramGrowth, float64(i)))
+}
+
+func (h *LatencyHistogram) add(d time.Duration) {
+	if h.counts == nil {
+		h.counts = map[int]uint64{}
+	}
+	h.counts[histogramBucketIndex(d)]++
+}
+
+// merge adds collected counts of o
+func (h *LatencyHistogram) merge(o *LatencyHistogram) {
+	if h.counts == nil {
+		h.counts = map[int]uint64{}
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+}
+
+// update computes exported buckets from collected counts
+func (h *LatencyHistogram) update() {
+	if len(h.counts) == 0 {
+		return
+	}
+	idx := make([]int, 0, len(h.counts))
+	for i := range h.counts {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	h.Buckets = make([]HistogramBucket, 0, len(idx))
+	for _, i := range idx {
+		h.Buckets = append(h.Buckets, HistogramBucket{UpperBound: histogramBucketBound(i), Count: h.counts[i]})
+	}
+}
+
+// Total returns number of observations
+func (h *LatencyHistogram) Total() uint64 {
+	var n uint64
+	for _, b := range h.Buckets {
+		n += b.Count
+	}
+	return n
+}
+
+// Quantile returns upper bound of the bucket containing q quantile
+func (h *LatencyHistogram) Quantile(q float64) time.Duration {
+	total := h.Total()
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	if rank == 0 {
+		rank = 1
+	}
+	var cum uint64
+	for _, b := range h.Buckets {
+		cum += b.Count
+		if cum >= rank {
+			return b.UpperBound
+		}
+	}
+	return h.Buckets[len(h.Buckets)-1].UpperBound
+}
+
+// MergeHistograms sums histograms, e.g. of several baseline runs
+func MergeHistograms(hs ...*LatencyHistogram) *LatencyHistogram {
+	counts := map[time.Duration]uint64{}
+	for _, h := range hs {
+		if h == nil {
+			continue
+		}
+		for _, b := range h.Buckets {
+			counts[b.UpperBound] += b.Count
+		}
+	}
+	merged := &LatencyHistogram{Buckets: make([]HistogramBucket, 0, len(counts))}
+	for bound, c := range counts {
+		merged.Buckets = append(merged.Buckets, HistogramBucket{UpperBound: bound, Count: c})
+	}
+	sort.Slice(merged.Buckets, func(i, j int) bool { return merged.Buckets[i].UpperBound < merged.Buckets[j].UpperBound })
+	return merged
+}
+
+// MannWhitneyGreater tests if current latencies are stochastically greater than baseline ones,
+// observations in one bucket are ties, returns U statistic of current sample and one-sided p-value
+// using normal approximation with tie correction
+func MannWhitneyGreater(baseline, current *LatencyHistogram) (u float64, pValue float64) {
+	nx := float64(baseline.Total())
+	ny := float64(current.Total())
+	if nx == 0 || ny == 0 {
+		return 0, 1
+	}
+	counts := map[time.Duration][2]float64{}
+	for _, b := range baseline.Buckets {
+		c := counts[b.UpperBound]
+		c[0] += float64(b.Count)
+		counts[b.UpperBound] = c
+	}
+	for _, b := range current.Buckets {
+		c := counts[b.UpperBound]
+		c[1] += float64(b.Count)
+		counts[b.UpperBound] = c
+	}
+	bounds := make([]time.Duration, 0, len(counts))
+	for bound := range counts {
+		bounds = append(bounds, bound)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	var rankSum, ties, seen float64
+	for _, bound := range bounds {
+		c := counts[bound]
+		t := c[0] + c[1]
+		midRank := seen + (t+1)/2
+		rankSum += c[1] * midRank
+		ties += t*t*t - t
+		seen += t
+	}
+	n := nx + ny
+	u = rankSum - ny*(ny+1)/2
+	mean := nx * ny / 2
+	variance := nx * ny / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return u, 1
+	}
+	z := (u - mean) / math.Sqrt(variance)
+	return u, 0.5 * math.Erfc(z/math.Sqrt2)
+}
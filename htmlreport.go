@@ -0,0 +1,432 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HTMLReportFileTmpl = "report-%d.html"
+
+	chartWidth  = 900
+	chartHeight = 240
+	chartMargin = 50
+)
+
+var verdictSeverity = map[string]int{"OK": 0, "UNRELIABLE": 1, "DEGRADED": 2, "FAILED": 3}
+
+var chartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// LoadRunReport reads run report from json file
+func LoadRunReport(path string) (*RunReport, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r RunReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal report %s: %s", path, err)
+	}
+	return &r, nil
+}
+
+// HandleFromReportPath returns handle name and timestamp of report file, e.g. transfer-1577836800.json
+func HandleFromReportPath(path string) (string, int64, bool) {
+	base := filepath.Base(path)
+	loc := handleReportRe.FindStringSubmatchIndex(base)
+	if loc == nil || loc[1] != len(base) {
+		return "", 0, false
+	}
+	ts, err := strconv.ParseInt(base[loc[2]:loc[3]], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return base[:loc[0]], ts, true
+}
+
+// LoadRunReports reads report files, for directories reports of the latest suite run are read
+func LoadRunReports(paths ...string) (map[string]*RunReport, error) {
+	reports := map[string]*RunReport{}
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{p}
+		if fi.IsDir() {
+			if files, err = latestSuiteReportFiles(p); err != nil {
+				return nil, err
+			}
+		}
+		for _, f := range files {
+			r, err := LoadRunReport(f)
+			if err != nil {
+				return nil, err
+			}
+			handleName, _, ok := HandleFromReportPath(f)
+			if !ok {
+				handleName = strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+			}
+			reports[handleName] = r
+		}
+	}
+	return reports, nil
+}
+
+// latestSuiteReportFiles returns handle report files with the latest timestamp in dir
+func latestSuiteReportFiles(dir string) ([]string, error) {
+	byTs, err := reportFilesByTs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(byTs) == 0 {
+		return nil, fmt.Errorf("no reports found in %s", dir)
+	}
+	var latest int64
+	for ts := range byTs {
+		if ts > latest {
+			latest = ts
+		}
+	}
+	return byTs[latest], nil
+}
+
+// reportFilesByTs returns handle report files in dir grouped by suite run timestamp
+func reportFilesByTs(dir string) (map[int64][]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	byTs := map[int64][]string{}
+	for _, fi := range infos {
+		if fi.IsDir() {
+			continue
+		}
+		_, ts, ok := HandleFromReportPath(fi.Name())
+		if !ok {
+			continue
+		}
+		byTs[ts] = append(byTs[ts], filepath.Join(dir, fi.Name()))
+	}
+	return byTs, nil
+}
+
+type chartSeries struct {
+	Name   string
+	Points []chartPoint
+}
+
+type chartPoint struct {
+	X float64
+	Y float64
+}
+
+// svgLineChart renders series as inline svg, x is seconds since start or days for "d" x unit
+func svgLineChart(series []chartSeries, yUnit string, xUnit string) template.HTML {
+	var maxX, maxY float64
+	for _, s := range series {
+		for _, p := range s.Points {
+			maxX = math.Max(maxX, p.X)
+			maxY = math.Max(maxY, p.Y)
+		}
+	}
+	if maxX == 0 {
+		maxX = 1
+	}
+	if maxY == 0 {
+		maxY = 1
+	}
+	plotW := float64(chartWidth - 2*chartMargin)
+	plotH := float64(chartHeight - 2*chartMargin)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight+20*len(series))
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#999"/>`, chartMargin, chartMargin, chartMargin, chartHeight-chartMargin)
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#999"/>`, chartMargin, chartHeight-chartMargin, chartWidth-chartMargin, chartHeight-chartMargin)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="11" text-anchor="end">%s</text>`, chartMargin-4, chartMargin+4, template.HTMLEscapeString(formatChartValue(maxY, yUnit)))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="11" text-anchor="end">0</text>`, chartMargin-4, chartHeight-chartMargin)
+	xLabel := fmt.Sprintf("%.0fs", maxX)
+	if xUnit == "d" {
+		xLabel = fmt.Sprintf("%.1fd", maxX)
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="11" text-anchor="end">%s</text>`, chartWidth-chartMargin, chartHeight-chartMargin+14, xLabel)
+	for i, s := range series {
+		color := chartColors[i%len(chartColors)]
+		pts := make([]string, 0, len(s.Points))
+		for _, p := range s.Points {
+			x := float64(chartMargin) + p.X/maxX*plotW
+			y := float64(chartHeight-chartMargin) - p.Y/maxY*plotH
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", x, y))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`, color, strings.Join(pts, " "))
+		ly := chartHeight + 20*i
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/>`, chartMargin, ly-10, color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="12">%s</text>`, chartMargin+18, ly, template.HTMLEscapeString(s.Name))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+func formatChartValue(v float64, unit string) string {
+	if unit == "ms" {
+		return fmt.Sprintf("%.1fms", v)
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+type htmlHandle struct {
+	Name          string
+	Verdict       string
+	Report        *RunReport
+	Labels        []string
+	Breakdown     []htmlBreakdown
+	Configuration string
+	LatencyChart  template.HTML
+	RateChart     template.HTML
+	ErrorsChart   template.HTML
+}
+
+// htmlBreakdown is metrics of label requests with one tag value
+type htmlBreakdown struct {
+	Label     string
+	Dimension string
+	Value     string
+	Metrics   *Metrics
+}
+
+// breakdownRows returns label metrics broken down by tag dimensions sorted by label, dimension and value
+func breakdownRows(label string, m *Metrics) []htmlBreakdown {
+	var res []htmlBreakdown
+	for dim, values := range m.Breakdown {
+		for value, tm := range values {
+			res = append(res, htmlBreakdown{Label: label, Dimension: dim, Value: value, Metrics: tm})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Dimension != res[j].Dimension {
+			return res[i].Dimension < res[j].Dimension
+		}
+		return res[i].Value < res[j].Value
+	})
+	return res
+}
+
+type htmlReport struct {
+	Title       string
+	GeneratedAt time.Time
+	Verdict     string
+	Handles     []htmlHandle
+}
+
+// ReportVerdict returns human readable verdict of handle report
+func ReportVerdict(r *RunReport) string {
+	switch {
+	case r.RunError != "" || r.Failed:
+		return "FAILED"
+	case r.Degradation:
+		return "DEGRADED"
+	case r.Unreliable:
+		return "UNRELIABLE"
+	default:
+		return "OK"
+	}
+}
+
+func newHTMLHandle(name string, r *RunReport) htmlHandle {
+	h := htmlHandle{Name: name, Verdict: ReportVerdict(r), Report: r}
+	for label := range r.Metrics {
+		h.Labels = append(h.Labels, label)
+	}
+	sort.Strings(h.Labels)
+	for _, label := range h.Labels {
+		h.Breakdown = append(h.Breakdown, breakdownRows(label, r.Metrics[label])...)
+	}
+	if data, err := json.MarshalIndent(redactedConfig(r.Configuration), "", "  "); err == nil {
+		h.Configuration = string(data)
+	}
+	start := r.StartedAt
+	var latency, rate, errs []chartSeries
+	for _, label := range h.Labels {
+		m := r.Metrics[label]
+		if len(m.Timeline) == 0 {
+			continue
+		}
+		if start.IsZero() || m.Timeline[0].Time.Before(start) {
+			start = m.Timeline[0].Time
+		}
+	}
+	for _, label := range h.Labels {
+		m := r.Metrics[label]
+		p50 := chartSeries{Name: label + " p50"}
+		p95 := chartSeries{Name: label + " p95"}
+		p99 := chartSeries{Name: label + " p99"}
+		rps := chartSeries{Name: label}
+		e := chartSeries{Name: label}
+		for _, p := range m.Timeline {
+			x := p.Time.Sub(start).Seconds()
+			// points of long attacks hold several seconds, rate and errors are per second
+			seconds := float64(p.Seconds)
+			if seconds < 1 {
+				seconds = 1
+			}
+			p50.Points = append(p50.Points, chartPoint{x, float64(p.P50) / float64(time.Millisecond)})
+			p95.Points = append(p95.Points, chartPoint{x, float64(p.P95) / float64(time.Millisecond)})
+			p99.Points = append(p99.Points, chartPoint{x, float64(p.P99) / float64(time.Millisecond)})
+			rps.Points = append(rps.Points, chartPoint{x, float64(p.Requests) / seconds})
+			e.Points = append(e.Points, chartPoint{x, float64(p.Errors) / seconds})
+		}
+		latency = append(latency, p50, p95, p99)
+		rate = append(rate, rps)
+		errs = append(errs, e)
+	}
+	h.LatencyChart = svgLineChart(latency, "ms", "s")
+	h.RateChart = svgLineChart(rate, "", "s")
+	h.ErrorsChart = svgLineChart(errs, "", "s")
+	return h
+}
+
+// WriteHTMLReport writes self-contained html page with charts for handle reports
+func WriteHTMLReport(w io.Writer, title string, reports map[string]*RunReport) error {
+	page := htmlReport{Title: title, GeneratedAt: time.Now(), Verdict: "OK"}
+	names := make([]string, 0, len(reports))
+	for name := range reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h := newHTMLHandle(name, reports[name])
+		if verdictSeverity[h.Verdict] > verdictSeverity[page.Verdict] {
+			page.Verdict = h.Verdict
+		}
+		page.Handles = append(page.Handles, h)
+	}
+	return htmlReportTmpl.Execute(w, page)
+}
+
+// WriteHTMLReportFile writes html report to file
+func WriteHTMLReportFile(path string, title string, reports map[string]*RunReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return WriteHTMLReport(f, title, reports)
+}
+
+var htmlReportTmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms": func(d time.Duration) string {
+		return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+	},
+	"percent": func(v float64) string {
+		return fmt.Sprintf("%.2f%%", v*100)
+	},
+	"rate": func(v float64) string {
+		return fmt.Sprintf("%.2f", v)
+	},
+	"time": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+	"verdictClass": func(v string) string {
+		return strings.ToLower(v)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 20px; color: #222; }
+table { border-collapse: collapse; margin-bottom: 16px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+pre { background: #f6f6f6; padding: 8px; overflow: auto; }
+.ok { color: #2ca02c; } .failed { color: #d62728; } .degraded { color: #ff7f0e; } .unreliable { color: #9467bd; }
+.verdict { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated at {{time .GeneratedAt}}, verdict: <span class="verdict {{verdictClass .Verdict}}">{{.Verdict}}</span></p>
+<h2>Summary</h2>
+<table>
+<tr><th>handle</th><th>label</th><th>requests</th><th>rate</th><th>success</th><th>p50</th><th>p95</th><th>p99</th><th>max</th><th>verdict</th></tr>
+{{range $h := .Handles}}{{range $l := $h.Labels}}{{with index $h.Report.Metrics $l}}
+<tr><td>{{$h.Name}}</td><td>{{$l}}</td><td>{{.Requests}}</td><td>{{rate .Rate}}</td><td>{{percent .Success}}</td><td>{{ms .Latencies.P50}}</td><td>{{ms .Latencies.P95}}</td><td>{{ms .Latencies.P99}}</td><td>{{ms .Latencies.Max}}</td><td class="verdict {{verdictClass $h.Verdict}}">{{$h.Verdict}}</td></tr>
+{{end}}{{end}}{{end}}
+</table>
+{{range .Handles}}
+<h2 id="{{.Name}}">{{.Name}} <span class="verdict {{verdictClass .Verdict}}">{{.Verdict}}</span></h2>
+<p>{{time .Report.StartedAt}} - {{time .Report.FinishedAt}}{{if .Report.DegradationCheck}}, degradation check: {{.Report.DegradationCheck}}{{else if .Report.DegradationRatio}}, p50 ratio to last successful run: {{rate .Report.DegradationRatio}}{{end}}</p>
+{{if .Report.RunError}}<p class="failed">Run error: {{.Report.RunError}}</p>{{end}}
+<h3>Latency percentiles</h3>
+{{.LatencyChart}}
+<h3>Throughput, requests per second</h3>
+{{.RateChart}}
+<h3>Errors per second</h3>
+{{.ErrorsChart}}
+<h3>Status codes</h3>
+<table>
+<tr><th>label</th><th>status code</th><th>count</th></tr>
+{{$r := .Report}}{{range $l := .Labels}}{{range $code, $count := (index $r.Metrics $l).StatusCodes}}
+<tr><td>{{$l}}</td><td>{{$code}}</td><td>{{$count}}</td></tr>
+{{end}}{{end}}
+</table>
+{{with .Breakdown}}
+<h3>Breakdown by tags</h3>
+<table>
+<tr><th>label</th><th>tag</th><th>value</th><th>requests</th><th>rate</th><th>success</th><th>p50</th><th>p95</th><th>p99</th><th>max</th></tr>
+{{range .}}<tr><td>{{.Label}}</td><td>{{.Dimension}}</td><td>{{.Value}}</td>{{with .Metrics}}<td>{{.Requests}}</td><td>{{rate .Rate}}</td><td>{{percent .Success}}</td><td>{{ms .Latencies.P50}}</td><td>{{ms .Latencies.P95}}</td><td>{{ms .Latencies.P99}}</td><td>{{ms .Latencies.Max}}</td>{{end}}</tr>
+{{end}}
+</table>
+{{end}}
+{{$r := .Report}}{{range $l := .Labels}}{{with (index $r.Metrics $l).Errors}}
+<h3>Errors of {{$l}}</h3>
+<ul>{{range .}}<li>{{.}}</li>{{end}}</ul>
+{{end}}{{end}}
+{{with .Report.Assertions}}
+<h3>SLO assertions</h3>
+<ul>{{range .}}<li class="{{if .Passed}}ok{{else}}failed{{end}}">{{.}}</li>{{end}}</ul>
+{{end}}
+{{with .Report.ThresholdViolations}}
+<h3>Threshold violations</h3>
+<ul>{{range .}}<li class="degraded">{{.}}</li>{{end}}</ul>
+{{end}}
+{{with .Report.GuardBreaches}}
+<h3>Guard breaches</h3>
+<ul>{{range .}}<li>{{time .At}} - {{time .LastSeen}} {{.Guard}}: {{.Value}}, peak {{.Peak}} (threshold {{.Threshold}}, {{.Action}})</li>{{end}}</ul>
+{{end}}
+{{with .Report.ConfigChanges}}
+<h3>Config changes</h3>
+<ul>{{range .}}<li>{{time .At}} {{.}}</li>{{end}}</ul>
+{{end}}
+{{with .Report.GeneratorHealth}}{{with .Warnings}}
+<h3>Generator warnings</h3>
+<ul>{{range .}}<li>{{.}}</li>{{end}}</ul>
+{{end}}{{end}}
+{{with .Report.Provenance}}
+<h3>Provenance</h3>
+<table>
+<tr><td>run id</td><td>{{.RunID}}</td></tr>
+<tr><td>hostname</td><td>{{.Hostname}}</td></tr>
+<tr><td>go version</td><td>{{.GoVersion}}</td></tr>
+<tr><td>loadgen version</td><td>{{.LoadgenVersion}}</td></tr>
+<tr><td>git commit</td><td>{{.GitCommit}}</td></tr>
+<tr><td>config</td><td>{{.ConfigFile}} sha256:{{.ConfigHash}}</td></tr>
+<tr><td>targets</td><td>{{range $i, $t := .Targets}}{{if $i}}, {{end}}{{$t}}{{end}}</td></tr>
+</table>
+{{end}}
+<h3>Configuration</h3>
+<pre>{{.Configuration}}</pre>
+{{end}}
+</body>
+</html>
+`))
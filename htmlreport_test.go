@@ -0,0 +1,93 @@
+package loadgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRunReport(p50 time.Duration) *RunReport {
+	now := time.Now()
+	m := new(Metrics)
+	for i := 0; i < 10; i++ {
+		m.add(result{
+			begin:    now.Add(time.Duration(i) * time.Second),
+			end:      now.Add(time.Duration(i)*time.Second + p50),
+			elapsed:  p50,
+			doResult: DoResult{RequestLabel: "transfer", StatusCode: 200},
+		})
+	}
+	m.updateLatencies()
+	return &RunReport{
+		StartedAt:  now,
+		FinishedAt: now.Add(10 * time.Second),
+		Metrics:    map[string]*Metrics{"transfer": m},
+		Output:     map[string]interface{}{},
+	}
+}
+
+func writeTestReport(t *testing.T, dir string, handleName string, ts int64, r *RunReport) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ReportFileName(handleName, ts)), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandleFromReportPath(t *testing.T) {
+	name, ts, ok := HandleFromReportPath("load/reports/member-transfer-1577836800.json")
+	if !ok {
+		t.Fatal("expected report path")
+	}
+	if got, want := name, "member-transfer"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := ts, int64(1577836800); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if _, _, ok := HandleFromReportPath("load/reports/transfer_last"); ok {
+		t.Error("expected not a report path")
+	}
+}
+
+func TestHTMLReportOfLatestSuite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestReport(t, dir, "old", 100, testRunReport(time.Millisecond))
+	failed := testRunReport(2 * time.Millisecond)
+	failed.Failed = true
+	failed.Metrics["transfer"].Breakdown = map[string]map[string]*Metrics{"region": {"eu-west": testRunReport(time.Millisecond).Metrics["transfer"]}}
+	writeTestReport(t, dir, "transfer", 200, failed)
+	writeTestReport(t, dir, "balance", 200, testRunReport(time.Millisecond))
+
+	reports, err := LoadRunReports(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(reports), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	var buf bytes.Buffer
+	if err := WriteHTMLReport(&buf, "suite", reports); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, want := range []string{`id="transfer"`, `id="balance"`, "<svg", "<polyline", "FAILED", "2.00ms", "Breakdown by tags", "eu-west"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected %q in html report", want)
+		}
+	}
+	if strings.Contains(page, `id="old"`) {
+		t.Error("expected only latest suite reports")
+	}
+}
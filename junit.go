@@ -0,0 +1,200 @@
+package loadgen
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	JUnitFileTmpl = "junit-%d.xml"
+	// JUnitHandleCase is a name of test case with failures of the whole handle run
+	JUnitHandleCase = "run"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// handleFailures returns failures of the whole handle, they are reported once in handle test case
+func handleFailures(r *RunReport) []junitFailure {
+	var res []junitFailure
+	if r.RunError != "" {
+		res = append(res, junitFailure{Message: r.RunError, Type: "run_error"})
+	}
+	if r.Degradation && len(r.ThresholdViolations) == 0 {
+		msg := fmt.Sprintf("p50 degradation ratio %.3f to last successful run", r.DegradationRatio)
+		if r.DegradationCheck != nil {
+			msg = "degradation " + r.DegradationCheck.String()
+		}
+		res = append(res, junitFailure{Message: msg, Type: "degradation"})
+	}
+	for _, a := range r.Assertions {
+		if !a.Passed && a.Label == "" {
+			res = append(res, junitFailure{Message: a.String(), Type: "assertion"})
+		}
+	}
+	for _, b := range r.GuardBreaches {
+		res = append(res, junitFailure{Message: b.String(), Type: "threshold_breach", Text: b.Query})
+	}
+	if r.Failed && len(res) == 0 {
+		res = append(res, junitFailure{Message: "handle run marked as failed", Type: "failed"})
+	}
+	return res
+}
+
+// mergeFailures joins failures of test case into one failure, junit test case has at most one
+func mergeFailures(fs []junitFailure) *junitFailure {
+	if len(fs) == 0 {
+		return nil
+	}
+	if len(fs) == 1 {
+		return &fs[0]
+	}
+	var types, messages, lines []string
+	for _, f := range fs {
+		if !containsString(types, f.Type) {
+			types = append(types, f.Type)
+		}
+		messages = append(messages, f.Message)
+		line := f.Type + ": " + f.Message
+		if f.Text != "" {
+			line += "\n" + f.Text
+		}
+		lines = append(lines, line)
+	}
+	return &junitFailure{
+		Message: fmt.Sprintf("%d failures: %s", len(fs), strings.Join(messages, "; ")),
+		Type:    strings.Join(types, ","),
+		Text:    strings.Join(lines, "\n"),
+	}
+}
+
+func junitSystemOut(r *RunReport, m *Metrics) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "requests: %d\n", m.Requests)
+	fmt.Fprintf(&b, "rate: %.2f\n", m.Rate)
+	fmt.Fprintf(&b, "success: %.2f%%\n", m.Success*100)
+	fmt.Fprintf(&b, "p50: %s\np95: %s\np99: %s\nmax: %s\n", m.Latencies.P50, m.Latencies.P95, m.Latencies.P99, m.Latencies.Max)
+	if r.Unreliable && r.GeneratorHealth != nil {
+		fmt.Fprintf(&b, "unreliable: %s\n", strings.Join(r.GeneratorHealth.Warnings, "; "))
+	}
+	return b.String()
+}
+
+// newJUnitSuite creates junit test suite with test case of every handle run and every handle label
+func newJUnitSuite(name string, reports map[string]*RunReport) junitTestSuite {
+	suite := junitTestSuite{Name: name}
+	var started, finished time.Time
+	handles := make([]string, 0, len(reports))
+	for h := range reports {
+		handles = append(handles, h)
+	}
+	sort.Strings(handles)
+	for _, handleName := range handles {
+		r := reports[handleName]
+		if started.IsZero() || (!r.StartedAt.IsZero() && r.StartedAt.Before(started)) {
+			started = r.StartedAt
+		}
+		if r.FinishedAt.After(finished) {
+			finished = r.FinishedAt
+		}
+		duration := fmt.Sprintf("%.3f", r.FinishedAt.Sub(r.StartedAt).Seconds())
+		suite.Cases = append(suite.Cases, junitTestCase{
+			ClassName: handleName,
+			Name:      JUnitHandleCase,
+			Time:      duration,
+			Failure:   mergeFailures(handleFailures(r)),
+		})
+		labels := make([]string, 0, len(r.Metrics))
+		for l := range r.Metrics {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			m := r.Metrics[label]
+			var failures []junitFailure
+			for _, a := range r.Assertions {
+				if !a.Passed && a.Label == label {
+					failures = append(failures, junitFailure{Message: a.String(), Type: "assertion"})
+				}
+			}
+			for _, v := range r.ThresholdViolations {
+				if v.Label == label {
+					failures = append(failures, junitFailure{Message: "degradation " + v.String(), Type: "degradation"})
+				}
+			}
+			if len(m.Errors) > 0 {
+				failures = append(failures, junitFailure{
+					Message: fmt.Sprintf("%d unique errors, success %.2f%%", len(m.Errors), m.Success*100),
+					Type:    "errors",
+					Text:    strings.Join(m.Errors, "\n"),
+				})
+			}
+			suite.Cases = append(suite.Cases, junitTestCase{
+				ClassName: handleName,
+				Name:      label,
+				Time:      duration,
+				Failure:   mergeFailures(failures),
+				SystemOut: junitSystemOut(r, m),
+			})
+		}
+	}
+	for _, tc := range suite.Cases {
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+	}
+	suite.Tests = len(suite.Cases)
+	suite.Time = fmt.Sprintf("%.3f", finished.Sub(started).Seconds())
+	suite.Timestamp = started.Format("2006-01-02T15:04:05")
+	return suite
+}
+
+// WriteJUnitReport writes junit xml with test case of every handle run and every handle label
+func WriteJUnitReport(w io.Writer, suiteName string, reports map[string]*RunReport) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(junitTestSuites{Suites: []junitTestSuite{newJUnitSuite(suiteName, reports)}})
+}
+
+// WriteJUnitReportFile writes junit xml report to file
+func WriteJUnitReportFile(path string, suiteName string, reports map[string]*RunReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return WriteJUnitReport(f, suiteName, reports)
+}
@@ -0,0 +1,80 @@
+package loadgen
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJUnitReport(t *testing.T) {
+	degraded := testRunReport(2 * time.Millisecond)
+	degraded.Degradation = true
+	degraded.DegradationRatio = 1.5
+	withErrors := testRunReport(time.Millisecond)
+	withErrors.Metrics["transfer"].Errors = []string{"connection refused"}
+	reports := map[string]*RunReport{
+		"balance":  testRunReport(time.Millisecond),
+		"transfer": degraded,
+		"fee":      withErrors,
+	}
+	var buf bytes.Buffer
+	if err := WriteJUnitReport(&buf, "prod-min", reports); err != nil {
+		t.Fatal(err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatal(err)
+	}
+	suite := suites.Suites[0]
+	if got, want := suite.Tests, 6; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := suite.Failures, 2; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	cases := map[string]junitTestCase{}
+	for _, tc := range suite.Cases {
+		cases[tc.ClassName+"/"+tc.Name] = tc
+	}
+	if cases["balance/run"].Failure != nil || cases["balance/transfer"].Failure != nil {
+		t.Error("expected passed balance handle")
+	}
+	if got, want := cases["transfer/run"].Failure.Type, "degradation"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if cases["transfer/transfer"].Failure != nil {
+		t.Error("handle failures must not be copied to label test cases")
+	}
+	if got, want := cases["fee/transfer"].Failure.Text, "connection refused"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if cases["balance/transfer"].SystemOut == "" {
+		t.Error("expected metrics in system-out")
+	}
+}
+
+func TestJUnitFailuresJoined(t *testing.T) {
+	r := testRunReport(time.Millisecond)
+	r.Metrics["transfer"].Errors = []string{"connection refused"}
+	r.Assertions = []AssertionResult{{Expr: "p99 < 1ms", Label: "transfer"}}
+	var buf bytes.Buffer
+	if err := WriteJUnitReport(&buf, "prod-min", map[string]*RunReport{"transfer": r}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := bytes.Count(buf.Bytes(), []byte("<failure")), 1; got != want {
+		t.Fatalf("got %v want %v:\n%s", got, want, buf.String())
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatal(err)
+	}
+	f := suites.Suites[0].Cases[1].Failure
+	if got, want := f.Type, "assertion,errors"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if !strings.Contains(f.Text, "connection refused") || !strings.Contains(f.Text, "p99 < 1ms") {
+		t.Errorf("expected all messages in failure text, got %s", f.Text)
+	}
+}
@@ -1,17 +1,17 @@
 package loadgen
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/insolar/x-crypto/ecdsa"
 	"github.com/spf13/viper"
-	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -40,10 +40,44 @@ type LoadManager struct {
 	// CsvStore stores data for all attackers
 	CsvStore  map[string]*CSVData
 	ReportDir string
+	// Store keeps handle reports and baselines, filesystem store in ReportDir when nil
+	Store ReportStore
+	// BaselineKey suite and environment of baselines, current config when empty
+	BaselineKey BaselineKey
+	// ReportTs timestamp of stored suite reports
+	ReportTs int64
 	// When degradation threshold is reached for any handle, see default config
 	Degradation bool
-	// When there are errors in any handle
+	// When there are errors in any handle or any handle report is marked as failed
 	Failed bool
+	// GuardBreaches all target-side guard breaches during suite run
+	GuardBreaches []GuardBreach
+	// When run was aborted by guard
+	Aborted bool
+	// When SLO assertion of any handle failed
+	AssertionsFailed bool
+	// Provenance of suite run, added to every handle report
+	Provenance *Provenance
+	// ExecutionMode is parallel or sequence
+	ExecutionMode string
+	// StartedAt and FinishedAt are bounds of suite run
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// SuiteReport is a summary of handle reports, set when it is stored
+	SuiteReport *SuiteReport
+	// RunError is set when suite run is stopped by signal or fatal error
+	RunError string
+
+	// statusMu guards guard breaches and suite status: Failed, Aborted, AssertionsFailed and RunError
+	statusMu sync.Mutex
+	abort    chan struct{}
+
+	// tracer exports spans of the run, nil when tracing is off
+	tracer      *Tracer
+	monitor     *RunMonitor
+	monitorInit sync.Once
+	failureOnce sync.Once
+	selfMonitor *SelfMonitor
 }
 
 // NewLoadManager create load manager with data files
@@ -54,6 +88,7 @@ func NewLoadManager() *LoadManager {
 		Reports:     make(map[string]*RunReport),
 		CsvStore:    make(map[string]*CSVData),
 		Degradation: false,
+		abort:       make(chan struct{}),
 	}
 	if lm.ReportDir, err = filepath.Abs(filepath.Join("load", "reports")); err != nil {
 		log.Fatal(err)
@@ -68,7 +103,7 @@ func (m *LoadManager) SetupHandleStore(handle Config) {
 		log.Printf("creating read file: %s\n", csvReadName)
 		f, err := os.Open(csvReadName)
 		if err != nil {
-			log.Fatalf("no csv read file found: %s", csvReadName)
+			m.Fatalf("no csv read file found: %s", csvReadName)
 		}
 		m.CsvStore[csvReadName] = NewCSVData(f, recycleData)
 	}
@@ -84,8 +119,11 @@ func (m *LoadManager) HandleShutdownSignal() {
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		<-sigs
+		sig := <-sigs
 		fmt.Println("exit signal received, exiting")
+		// reports are not changed by handles after the lock is taken
+		m.CsvMu.Lock()
+		m.notifyFailure(fmt.Sprintf("interrupted by %s signal", sig))
 		m.Shutdown()
 		os.Exit(1)
 	}()
@@ -96,6 +134,16 @@ func (m *LoadManager) Shutdown() {
 		s.Flush()
 		s.f.Close()
 	}
+	m.monitor.Stop()
+	m.tracer.Shutdown()
+}
+
+// Monitor returns metrics registry of the run, created on first call
+func (m *LoadManager) Monitor() *RunMonitor {
+	m.monitorInit.Do(func() {
+		m.monitor = NewRunMonitor()
+	})
+	return m.monitor
 }
 
 // RunSuite starts suite and wait for all generator to shutdown
@@ -103,10 +151,25 @@ func (m *LoadManager) RunSuite() {
 	m.HandleShutdownSignal()
 
 	t := timeNow()
+	m.StartedAt = t
 	startTime := epochNowMillis(t)
 	hrStartTime := timeHumanReadable(t)
+	guards := NewGuardMonitor(m)
+	guards.Start()
+	m.selfMonitor = NewSelfMonitor()
+	m.selfMonitor.Start()
+	var dashboard *TerminalDashboard
+	if *oDashboard || viper.GetBool("dashboard") {
+		dashboard = NewTerminalDashboard(m.Groups, os.Stdout)
+	}
+	dashboard.Start()
+	if viper.GetBool("hot_reload") {
+		stopWatch := m.WatchSuiteConfig()
+		defer stopWatch()
+	}
 	mode := viper.GetString("execution_mode")
-	if mode == "parallel" {
+	m.ExecutionMode = mode
+	if mode == ExecutionModeParallel {
 		var wg sync.WaitGroup
 		wg.Add(len(m.Groups))
 
@@ -115,16 +178,24 @@ func (m *LoadManager) RunSuite() {
 			go r.Run(&wg, m)
 		}
 		wg.Wait()
-	} else if mode == "sequence" {
+	} else if mode == ExecutionModeSequence {
 		// Used to prepare data by sequence of tests
 		SortGroupsBySequenceNum(m.Groups)
 		for _, r := range m.Groups {
 			r.SetupHandleStore(m)
 			r.Run(nil, m)
 		}
+	} else if mode == ExecutionModeDAG {
+		m.runGraph()
+	} else {
+		m.Fatalf("unknown execution_mode [%s], possible values are {parallel,sequence,dag}", mode)
 	}
+	dashboard.Stop()
+	guards.Stop()
+	m.selfMonitor.Stop()
 
 	t = timeNow()
+	m.FinishedAt = t
 	finishTime := epochNowMillis(t)
 	hrFinishTime := timeHumanReadable(t)
 
@@ -134,104 +205,285 @@ func (m *LoadManager) RunSuite() {
 	m.Shutdown()
 }
 
+// AddGuardBreach records guard breach, fails suite and aborts it if guard action is abort
+func (m *LoadManager) AddGuardBreach(b GuardBreach) {
+	log.Printf("[ guards ] %s, action: %s\n", b, b.Action)
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	m.GuardBreaches = append(m.GuardBreaches, b)
+	m.Failed = true
+	if b.Action == GuardActionAbort && !m.Aborted {
+		m.Aborted = true
+		if m.abort != nil {
+			close(m.abort)
+		}
+	}
+}
+
+// markFailed marks suite as failed, assertionsFailed is set when SLO assertions of handle failed
+func (m *LoadManager) markFailed(assertionsFailed bool) {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	m.Failed = true
+	if assertionsFailed {
+		m.AssertionsFailed = true
+	}
+}
+
+// updateGuardBreach updates last seen time and peak of breach of guard which stays breached
+func (m *LoadManager) updateGuardBreach(b GuardBreach) {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	for i := len(m.GuardBreaches) - 1; i >= 0; i-- {
+		if m.GuardBreaches[i].Guard == b.Guard && m.GuardBreaches[i].At.Equal(b.At) {
+			m.GuardBreaches[i] = b
+			return
+		}
+	}
+}
+
+// GuardBreachesBetween returns guard breaches overlapping time range
+func (m *LoadManager) GuardBreachesBetween(from time.Time, to time.Time) []GuardBreach {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	var res []GuardBreach
+	for _, b := range m.GuardBreaches {
+		if !b.At.After(to) && !b.LastSeen.Before(from) {
+			res = append(res, b)
+		}
+	}
+	return res
+}
+
+// IsAborted returns true if suite was aborted by guard
+func (m *LoadManager) IsAborted() bool {
+	select {
+	case <-m.abort:
+		return true
+	default:
+		return false
+	}
+}
+
 func (m *LoadManager) CsvForHandle(name string) *CSVData {
+	// handles of dag suite setup their stores while other handles run
+	m.CsvMu.Lock()
 	s, ok := m.CsvStore[name]
+	m.CsvMu.Unlock()
 	if !ok {
-		log.Fatalf("no csv storage file found for: %s", name)
+		m.Fatalf("no csv storage file found for: %s", name)
 	}
 	return s
 }
 
+// ReportFileName returns report file name of handle run
+func ReportFileName(handleName string, ts int64) string {
+	return fmt.Sprintf(ReportFileTmpl, handleName, ts)
+}
+
 // StoreHandleReports stores report for every handle in suite
 func (m *LoadManager) StoreHandleReports() {
 	ts := time.Now().Unix()
+	m.ReportTs = ts
 	for handleName, r := range m.Reports {
-		b, err := json.MarshalIndent(r, "", "    ")
-		if err != nil {
-			log.Fatal(err)
+		log.Printf("writing report for handle [%s] as %s", handleName, ReportFileName(handleName, ts))
+		if err := PutRunReport(m.store(), handleName, ts, r); err != nil {
+			m.Fatalf("%s", err)
 		}
-		repPath := filepath.Join(m.ReportDir, fmt.Sprintf(ReportFileTmpl, handleName, ts))
-		log.Printf("writing report for handle [%s] in %s", handleName, repPath)
-		if err := ioutil.WriteFile(repPath, b, 0777); err != nil {
-			log.Fatal(err)
-		}
-		if !m.Degradation {
+		if !m.Degradation && !m.IsAborted() && !r.Failed {
 			m.WriteLastSuccess(handleName, ts)
 		}
 	}
 }
 
-// WriteLastSuccess writes ts of last successful run for handle
+// StoreHTMLReport writes html report of suite next to handle reports
+func (m *LoadManager) StoreHTMLReport() {
+	if err := os.MkdirAll(m.ReportDir, 0777); err != nil {
+		m.Fatalf("%s", err)
+	}
+	repPath := filepath.Join(m.ReportDir, fmt.Sprintf(HTMLReportFileTmpl, m.ReportTs))
+	log.Printf("writing html report in %s", repPath)
+	title := fmt.Sprintf("Load test report %s", time.Unix(m.ReportTs, 0).Format(time.RFC3339))
+	if err := WriteHTMLReportFile(repPath, title, m.Reports); err != nil {
+		m.Fatalf("%s", err)
+	}
+}
+
+// StoreJUnitReport writes junit xml report of suite to reports.junit_file or next to handle reports
+func (m *LoadManager) StoreJUnitReport() {
+	repPath := viper.GetString("reports.junit_file")
+	if repPath == "" {
+		repPath = filepath.Join(m.ReportDir, fmt.Sprintf(JUnitFileTmpl, m.ReportTs))
+	}
+	if err := os.MkdirAll(filepath.Dir(repPath), 0777); err != nil {
+		m.Fatalf("%s", err)
+	}
+	log.Printf("writing junit report in %s", repPath)
+	if err := WriteJUnitReportFile(repPath, SuiteName(), m.Reports); err != nil {
+		m.Fatalf("%s", err)
+	}
+}
+
+// store returns report store of suite
+func (m *LoadManager) store() ReportStore {
+	if m.Store == nil {
+		return NewFileReportStore(m.ReportDir)
+	}
+	return m.Store
+}
+
+// baselineKey returns key of baselines the suite is compared to
+func (m *LoadManager) baselineKey() BaselineKey {
+	if m.BaselineKey == (BaselineKey{}) {
+		return CurrentBaselineKey()
+	}
+	return m.BaselineKey
+}
+
+// WriteLastSuccess appends ts of successful run to handle baseline history of suite and environment
 func (m *LoadManager) WriteLastSuccess(handleName string, ts int64) {
-	lastSuccessFile := filepath.Join(m.ReportDir, handleName+"_last")
-	createIfNotExists(lastSuccessFile)
-	err := ioutil.WriteFile(lastSuccessFile, []byte(strconv.Itoa(int(ts))), 0777)
+	b, err := LoadBaseline(m.store(), m.baselineKey())
 	if err != nil {
-		log.Fatal(err)
+		m.Fatalf("%s", err)
+	}
+	b.AddRun(handleName, ts)
+	if err := b.Save(m.store()); err != nil {
+		m.Fatalf("%s", err)
+	}
+}
+
+// SuccessHistoryForHandle returns ts of baseline runs for handle, oldest first: pinned runs or last n green runs
+// of suite and environment, for report dirs without baselines legacy *handle_name*_history or _last file is read
+func (m *LoadManager) SuccessHistoryForHandle(handleName string, n int) ([]int64, error) {
+	b, err := LoadBaseline(m.store(), m.baselineKey())
+	if err != nil {
+		return nil, err
+	}
+	if hb, ok := b.Handles[handleName]; ok {
+		return hb.Window(n), nil
+	}
+	data, err := m.store().Get(handleName + "_history")
+	if os.IsNotExist(err) {
+		data, err = m.store().Get(handleName + "_last")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var history []int64
+	for _, line := range strings.Fields(string(data)) {
+		ts, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, ts)
+	}
+	if len(history) > n {
+		history = history[len(history)-n:]
 	}
+	return history, nil
+}
+
+// LastSuccessReportsForHandle gets up to n baseline reports for a handle with their ts,
+// reports removed from reports dir are skipped
+func (m *LoadManager) LastSuccessReportsForHandle(handleName string, n int) ([]*RunReport, []int64, error) {
+	history, err := m.SuccessHistoryForHandle(handleName, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	reports := make([]*RunReport, 0, len(history))
+	found := make([]int64, 0, len(history))
+	for _, ts := range history {
+		r, err := GetRunReport(m.store(), handleName, ts)
+		if os.IsNotExist(err) {
+			log.Printf("baseline report of %s handle run %d not found, skipping", handleName, ts)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		reports = append(reports, r)
+		found = append(found, ts)
+	}
+	if len(reports) == 0 {
+		return nil, nil, os.ErrNotExist
+	}
+	return reports, found, nil
 }
 
 // CheckErrors check errors logic
 func (m *LoadManager) CheckErrors() {
 	for handleName, currentReport := range m.Reports {
 		if len(currentReport.Metrics[handleName].Errors) > 0 {
-			m.Failed = true
+			m.markFailed(false)
 		}
 	}
 }
 
-// CheckDegradation checks handle performance degradation to last successful run stored in *handle_name*_last file
+// CheckDegradation checks metrics of every handle label against configured thresholds, relative limits
+// are compared to last checks.baseline_runs successful runs stored in *handle_name*_history file,
+// handles without metric thresholds are checked for p50 degradation over checks.handle_threshold_percent
 func (m *LoadManager) CheckDegradation() {
 	handleThreshold := viper.GetFloat64("checks.handle_threshold_percent")
+	alpha := viperFloatOrDefault("checks.p_value_threshold", defaultPValueThreshold)
+	n := baselineRuns()
 	for handleName, currentReport := range m.Reports {
-		lastReport, err := m.LastSuccessReportForHandle(handleName)
+		thresholds := metricThresholds(currentReport.Configuration.Thresholds)
+		if len(thresholds) == 0 {
+			thresholds = []Threshold{{Label: handleName, Metric: ThresholdP50, Relative: handleThreshold}}
+		}
+		lastReports, history, err := m.LastSuccessReportsForHandle(handleName, n)
 		if os.IsNotExist(err) {
-			log.Printf("nothing to compare for %s handle, no reports in %s", handleName, m.ReportDir)
-			continue
+			log.Printf("nothing to compare for %s handle, no baseline reports, checking absolute thresholds only", handleName)
+		} else if err != nil {
+			m.Fatalf("%s", err)
+		}
+		labels := make([]string, 0, len(currentReport.Metrics))
+		for label := range currentReport.Metrics {
+			labels = append(labels, label)
 		}
-		if _, ok := lastReport.Metrics[handleName]; !ok {
-			log.Fatalf("no last report for handle %s found in last report", handleName)
+		sort.Strings(labels)
+		currentReport.ThresholdViolations = nil
+		for _, label := range labels {
+			current := currentReport.Metrics[label]
+			baselines := make([]*Metrics, 0, len(lastReports))
+			for _, lastReport := range lastReports {
+				if lastMetrics, ok := lastReport.Metrics[label]; ok {
+					baselines = append(baselines, lastMetrics)
+				}
+			}
+			if label == handleName && len(baselines) > 0 {
+				p50Threshold := handleThreshold
+				for _, t := range thresholdsForLabel(thresholds, label) {
+					if t.Metric == ThresholdP50 && t.Relative > 0 {
+						p50Threshold = t.Relative
+					}
+				}
+				check := NewDegradationCheck(current, baselines, p50Threshold, alpha)
+				check.BaselineRuns = history
+				currentReport.DegradationCheck = check
+				currentReport.DegradationRatio = check.Ratio
+				fmt.Printf("[ %s ] %s\n", handleName, check)
+			}
+			violations := CheckThresholds(label, current, baselines, thresholds, alpha)
+			currentReport.ThresholdViolations = append(currentReport.ThresholdViolations, violations...)
 		}
-		if _, ok := currentReport.Metrics[handleName]; !ok {
-			log.Fatalf("no last report for handle %s found in current report", handleName)
+		for _, v := range currentReport.ThresholdViolations {
+			log.Printf("degradation of %s handle: %s", handleName, v)
 		}
-		currentMean := currentReport.Metrics[handleName].Latencies.P50 / time.Millisecond
-		lastMean := lastReport.Metrics[handleName].Latencies.P50 / time.Millisecond
-		fmt.Printf("[ %s ] current: %dms, last: %dms\n", handleName, currentMean, lastMean)
-		fmt.Printf("ratio: %f\n", float64(currentMean)/float64(lastMean))
-		if float64(currentMean)/float64(lastMean) >= handleThreshold {
-			log.Printf("p50 degradation of %s handle: %d > %d", handleName, currentMean, lastMean)
+		if len(currentReport.ThresholdViolations) > 0 {
+			currentReport.Degradation = true
 			m.Degradation = true
-			continue
 		}
 	}
 }
 
-// LastSuccessReportForHandle gets last successful report for a handle
+// LastSuccessReportForHandle gets last baseline report for a handle
 func (m *LoadManager) LastSuccessReportForHandle(handleName string) (*RunReport, error) {
-	f, err := os.Open(filepath.Join(m.ReportDir, handleName+"_last"))
-	defer f.Close()
+	reports, _, err := m.LastSuccessReportsForHandle(handleName, 1)
 	if err != nil {
 		return nil, err
 	}
-	lastTs, err := ioutil.ReadAll(f)
-	if err != nil {
-		log.Fatal(err)
-	}
-	lf, err := os.Open(filepath.Join(m.ReportDir, fmt.Sprintf("%s-%s.json", handleName, string(lastTs))))
-	defer lf.Close()
-	if err != nil {
-		log.Fatal(err)
-	}
-	data, err := ioutil.ReadAll(lf)
-	if err != nil {
-		log.Fatal(err)
-	}
-	var runReport RunReport
-	if err := json.Unmarshal(data, &runReport); err != nil {
-		log.Fatal(err)
-	}
-	return &runReport, nil
+	return reports[len(reports)-1], nil
 }
 
 // createIfNotExists creates file if not exists, used to not override csv data
@@ -1,6 +1,8 @@
 package loadgen
 
 import (
+	"math"
+	"sort"
 	"strconv"
 	"time"
 
@@ -9,6 +11,9 @@ import (
 
 // this file is a modified version from https://github.com/tsenart/vegeta/blob/master/lib/metrics.go
 
+// maxTimelinePoints limits stored timeline, seconds of longer attacks are merged into wider points
+const maxTimelinePoints = 600
+
 type (
 	// Metrics holds metrics computed out of a slice of Results which are used
 	// in some of the Reporters
@@ -35,10 +40,43 @@ type (
 		StatusCodes map[string]int `json:"status_codes"`
 		// Errors is a set of unique errors returned by the targets during the attack.
 		Errors []string `json:"errors"`
+		// Breakdown holds metrics split by configured tag dimensions: dimension -> tag value -> metrics.
+		Breakdown map[string]map[string]*Metrics `json:"breakdown,omitempty"`
+		// Timeline holds metrics for every second of the attack, or for every few seconds
+		// when attack is longer than maxTimelinePoints seconds.
+		Timeline []TimelinePoint `json:"timeline,omitempty"`
+		// Histogram is the latency distribution used for regression detection.
+		Histogram *LatencyHistogram `json:"histogram,omitempty"`
 
 		errors    map[string]struct{}
 		success   uint64
 		latencies *quantile.Estimator
+		timeline  map[int64]*timelineBucket
+	}
+
+	// TimelinePoint holds metrics of requests started during one or several seconds.
+	TimelinePoint struct {
+		// Time is the beginning of the first second.
+		Time time.Time `json:"time"`
+		// Seconds is the number of seconds merged into the point.
+		Seconds int64 `json:"seconds"`
+		// Requests is the number of requests started.
+		Requests uint64 `json:"requests"`
+		// Errors is the number of failed requests.
+		Errors uint64 `json:"errors"`
+		// P50 is the 50th percentile request latency.
+		P50 time.Duration `json:"50th"`
+		// P95 is the 95th percentile request latency.
+		P95 time.Duration `json:"95th"`
+		// P99 is the 99th percentile request latency.
+		P99 time.Duration `json:"99th"`
+	}
+
+	// timelineBucket is a fixed size aggregate of one second, latencies are kept in log buckets
+	timelineBucket struct {
+		requests  uint64
+		errors    uint64
+		latencies LatencyHistogram
 	}
 
 	// LatencyMetrics holds computed request latency metrics.
@@ -85,6 +123,7 @@ func (m *Metrics) add(r result) {
 	m.Latencies.Total += r.elapsed
 
 	m.latencies.Add(float64(r.elapsed))
+	m.Histogram.add(r.elapsed)
 
 	if m.Earliest.IsZero() || m.Earliest.After(r.begin) {
 		m.Earliest = r.begin
@@ -102,6 +141,18 @@ func (m *Metrics) add(r result) {
 		m.Latencies.Max = r.elapsed
 	}
 
+	sec := r.begin.Unix()
+	b, ok := m.timeline[sec]
+	if !ok {
+		b = &timelineBucket{}
+		m.timeline[sec] = b
+	}
+	b.requests++
+	b.latencies.add(r.elapsed)
+	if r.doResult.Error != nil || r.doResult.StatusCode >= 400 {
+		b.errors++
+	}
+
 	if r.doResult.Error != nil {
 		if _, ok := m.errors[r.doResult.Error.Error()]; !ok {
 			m.errors[r.doResult.Error.Error()] = struct{}{}
@@ -114,9 +165,39 @@ func (m *Metrics) add(r result) {
 	}
 }
 
+// addTagged adds result to metrics and to breakdown by every dimension found in result tags.
+func (m *Metrics) addTagged(r result, dimensions []string) {
+	m.add(r)
+	for _, dim := range dimensions {
+		value, ok := r.doResult.Tags[dim]
+		if !ok {
+			continue
+		}
+		if m.Breakdown == nil {
+			m.Breakdown = map[string]map[string]*Metrics{}
+		}
+		values, ok := m.Breakdown[dim]
+		if !ok {
+			values = map[string]*Metrics{}
+			m.Breakdown[dim] = values
+		}
+		tm, ok := values[value]
+		if !ok {
+			tm = new(Metrics)
+			values[value] = tm
+		}
+		tm.add(r)
+	}
+}
+
 // updateLatencies computes derived summary metrics which don't need to be Run on every add call.
 func (m *Metrics) updateLatencies() {
 	m.init()
+	for _, values := range m.Breakdown {
+		for _, tm := range values {
+			tm.updateLatencies()
+		}
+	}
 	fRequests := float64(m.Requests)
 	m.Duration = m.Latest.Sub(m.Earliest)
 	if secs := m.Duration.Seconds(); secs > 0 {
@@ -128,16 +209,73 @@ func (m *Metrics) updateLatencies() {
 	m.Latencies.P50 = time.Duration(m.latencies.Get(0.50))
 	m.Latencies.P95 = time.Duration(m.latencies.Get(0.95))
 	m.Latencies.P99 = time.Duration(m.latencies.Get(0.99))
+	m.Histogram.update()
+	m.updateTimeline()
+}
+
+// updateTimeline computes timeline points from per second buckets, seconds are merged
+// into points of equal width so there are at most maxTimelinePoints points.
+func (m *Metrics) updateTimeline() {
+	if len(m.timeline) == 0 {
+		return
+	}
+	first, last := int64(math.MaxInt64), int64(math.MinInt64)
+	for sec := range m.timeline {
+		if sec < first {
+			first = sec
+		}
+		if sec > last {
+			last = sec
+		}
+	}
+	step := (last-first)/maxTimelinePoints + 1
+	points := map[int64]*timelineBucket{}
+	for sec, b := range m.timeline {
+		start := first + (sec-first)/step*step
+		p, ok := points[start]
+		if !ok {
+			p = &timelineBucket{}
+			points[start] = p
+		}
+		p.requests += b.requests
+		p.errors += b.errors
+		p.latencies.merge(&b.latencies)
+	}
+	starts := make([]int64, 0, len(points))
+	for start := range points {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	m.Timeline = make([]TimelinePoint, 0, len(starts))
+	for _, start := range starts {
+		p := points[start]
+		p.latencies.update()
+		m.Timeline = append(m.Timeline, TimelinePoint{
+			Time:     time.Unix(start, 0),
+			Seconds:  step,
+			Requests: p.requests,
+			Errors:   p.errors,
+			P50:      p.latencies.Quantile(0.50),
+			P95:      p.latencies.Quantile(0.95),
+			P99:      p.latencies.Quantile(0.99),
+		})
+	}
 }
 
 func (m *Metrics) init() {
 	if m.latencies == nil {
 		m.StatusCodes = map[string]int{}
 		m.errors = map[string]struct{}{}
-		m.latencies = quantile.New(
-			quantile.Known(0.50, 0.01),
-			quantile.Known(0.95, 0.001),
-			quantile.Known(0.99, 0.0005),
-		)
+		m.timeline = map[int64]*timelineBucket{}
+		m.Histogram = &LatencyHistogram{}
+		m.latencies = newLatencyEstimator()
 	}
 }
+
+func newLatencyEstimator() *quantile.Estimator {
+	return quantile.New(
+		quantile.Known(0.50, 0.01),
+		quantile.Known(0.95, 0.001),
+		quantile.Known(0.99, 0.0005),
+	)
+}
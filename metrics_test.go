@@ -0,0 +1,107 @@
+package loadgen
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricsBreakdownByTags(t *testing.T) {
+	m := new(Metrics)
+	now := time.Now()
+	add := func(region string, tenant string) {
+		m.addTagged(result{
+			begin:    now,
+			end:      now.Add(time.Millisecond),
+			elapsed:  time.Millisecond,
+			doResult: DoResult{RequestLabel: "transfer", Tags: map[string]string{"region": region, "tenant": tenant}},
+		}, []string{"region"})
+	}
+	add("eu", "a")
+	add("eu", "b")
+	add("us", "a")
+	m.updateLatencies()
+	if got, want := m.Requests, uint64(3); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := m.Breakdown["region"]["eu"].Requests, uint64(2); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := m.Breakdown["region"]["us"].Latencies.P50, time.Millisecond; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if _, ok := m.Breakdown["tenant"]; ok {
+		t.Error("expected no breakdown for not configured dimension")
+	}
+}
+
+func TestTaggedMetricLabels(t *testing.T) {
+	labels := taggedMetricLabels(DoResult{
+		RequestLabel: "transfer",
+		Tags:         map[string]string{"region": "eu.west", "size": "big"},
+	}, []string{"region", "tenant"})
+	if got, want := len(labels), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := labels[1], "tags.region.eu_west.transfer"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestMetricsTimeline(t *testing.T) {
+	m := new(Metrics)
+	start := time.Unix(1000, 0)
+	for i := 0; i < 200; i++ {
+		begin := start.Add(time.Duration(i) * 10 * time.Millisecond)
+		elapsed := time.Duration(1+i%100) * time.Millisecond
+		r := result{begin: begin, end: begin.Add(elapsed), elapsed: elapsed}
+		if i%50 == 0 {
+			r.doResult.StatusCode = 500
+		}
+		m.add(r)
+	}
+	m.updateLatencies()
+	if got, want := len(m.Timeline), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	p := m.Timeline[1]
+	if got, want := p.Seconds, int64(1); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := p.Requests, uint64(100); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := p.Errors, uint64(2); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	// latencies are approximated by histogram buckets within 5%
+	if p.P50 < 50*time.Millisecond || p.P50 > 53*time.Millisecond {
+		t.Errorf("unexpected p50 %v", p.P50)
+	}
+	if p.P99 < 99*time.Millisecond || p.P99 > 104*time.Millisecond {
+		t.Errorf("unexpected p99 %v", p.P99)
+	}
+}
+
+func TestMetricsTimelineDownsampled(t *testing.T) {
+	m := new(Metrics)
+	start := time.Unix(1000, 0)
+	// 4h soak
+	for sec := 0; sec < 4*3600; sec++ {
+		begin := start.Add(time.Duration(sec) * time.Second)
+		m.add(result{begin: begin, end: begin.Add(time.Millisecond), elapsed: time.Millisecond})
+	}
+	m.updateLatencies()
+	if got := len(m.Timeline); got > maxTimelinePoints {
+		t.Fatalf("got %v points, want at most %v", got, maxTimelinePoints)
+	}
+	p := m.Timeline[0]
+	if got, want := p.Seconds, int64(24); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := p.Requests, uint64(24); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if p.P99 < time.Millisecond || p.P99 > 1050*time.Microsecond {
+		t.Errorf("unexpected p99 %v", p.P99)
+	}
+}
@@ -2,131 +2,265 @@ package loadgen
 
 import (
 	"context"
-	"github.com/spf13/viper"
 	"log"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
 	graphite "github.com/cyberdelia/go-metrics-graphite"
 	"github.com/rcrowley/go-metrics"
+	"github.com/spf13/viper"
 )
 
-var (
-	timers                      map[string]metrics.Timer
-	errors                      map[string]metrics.Counter
-	timerMutex                  sync.RWMutex
-	errorMutext                 sync.RWMutex
-	gauge                       metrics.Gauge
+const defaultMonitoredHandle = "default"
+
+// RunMonitor holds metrics registry of one suite run and flushes it to graphite
+type RunMonitor struct {
+	Registry                    metrics.Registry
 	pulseDiff                   metrics.Gauge
 	observerTotalRecordsFetched metrics.Gauge
-	goroutinesCount             int64 = 0
-	monitorInit                 sync.Once
-)
-
-type Monitored struct {
-	Attack
+	handlesMu                   sync.Mutex
+	handles                     map[string]*HandleMonitor
+	quit                        chan struct{}
+	done                        chan struct{}
+	stop                        sync.Once
 }
 
-func WithMonitor(a Attack) Monitored {
-	return Monitored{a}
+// HandleMonitor holds metrics of one handle, metric names are prefixed with handle name
+type HandleMonitor struct {
+	Registry    metrics.Registry
+	timerMutex  sync.RWMutex
+	timers      map[string]metrics.Timer
+	errorMutex  sync.RWMutex
+	errors      map[string]metrics.Counter
+	goroutines  metrics.Gauge
+	goroutinesN int64
+	countMutex  sync.Mutex
 }
 
-func initMonitoring() {
+// NewRunMonitor creates run metrics registry, metrics are flushed to graphite if graphite.url is set
+func NewRunMonitor() *RunMonitor {
+	rm := &RunMonitor{
+		Registry:                    metrics.NewRegistry(),
+		pulseDiff:                   metrics.NewGauge(),
+		observerTotalRecordsFetched: metrics.NewGauge(),
+		handles:                     map[string]*HandleMonitor{},
+		quit:                        make(chan struct{}),
+		done:                        make(chan struct{}),
+	}
+	if err := rm.Registry.Register("pulseDiff", rm.pulseDiff); err != nil {
+		log.Fatal(err)
+	}
+	if err := rm.Registry.Register("totalPulseFetched", rm.observerTotalRecordsFetched); err != nil {
+		log.Fatal(err)
+	}
 	url := viper.GetString("graphite.url")
-	flushDuration := time.Duration(viper.GetInt("graphite.flushDurationSec"))
-	loadratorPrefix := viper.GetString("graphite.loadGeneratorPrefix")
-
+	if url == "" {
+		close(rm.done)
+		return rm
+	}
 	log.Println("[ grafana-monitoring ] setup graphite")
 	log.Printf("[ grafana-monitoring ] url: %s\n", url)
 	addr, err := net.ResolveTCPAddr("tcp", url)
 	if err != nil {
 		log.Fatalf("[grafana-monitoring] ResolveTCPAddr on [%s] failed error [%v] ", url, err)
 	}
-	go graphite.Graphite(
-		metrics.DefaultRegistry,
-		flushDuration*time.Second,
-		loadratorPrefix,
-		addr,
-	)
-	gauge = metrics.NewGauge()
-	pulseDiff = metrics.NewGauge()
-	observerTotalRecordsFetched = metrics.NewGauge()
-	timers = map[string]metrics.Timer{}
-	errors = map[string]metrics.Counter{}
-	err = metrics.Register("goroutines-goroutinesCount", gauge)
-	if err != nil {
-		log.Fatal(err)
+	go rm.flushLoop(graphite.Config{
+		Addr:          addr,
+		Registry:      rm.Registry,
+		FlushInterval: time.Duration(viper.GetInt("graphite.flushDurationSec")) * time.Second,
+		DurationUnit:  time.Nanosecond,
+		Prefix:        viper.GetString("graphite.loadGeneratorPrefix"),
+		Percentiles:   []float64{0.5, 0.75, 0.95, 0.99, 0.999},
+	})
+	return rm
+}
+
+func (rm *RunMonitor) flushLoop(c graphite.Config) {
+	defer close(rm.done)
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = time.Second
 	}
-	err = metrics.Register("pulseDiff", pulseDiff)
-	if err != nil {
-		log.Fatal(err)
+	ticker := time.NewTicker(c.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := graphite.Once(c); err != nil {
+				log.Println(err)
+			}
+		case <-rm.quit:
+			if err := graphite.Once(c); err != nil {
+				log.Println(err)
+			}
+			return
+		}
 	}
-	err = metrics.Register("totalPulseFetched", observerTotalRecordsFetched)
-	if err != nil {
+}
+
+// Handle returns metrics of a handle, creating them on first call
+func (rm *RunMonitor) Handle(name string) *HandleMonitor {
+	if name == "" {
+		name = defaultMonitoredHandle
+	}
+	rm.handlesMu.Lock()
+	defer rm.handlesMu.Unlock()
+	if h, ok := rm.handles[name]; ok {
+		return h
+	}
+	h := &HandleMonitor{
+		Registry:   metrics.NewPrefixedChildRegistry(rm.Registry, name+"."),
+		timers:     map[string]metrics.Timer{},
+		errors:     map[string]metrics.Counter{},
+		goroutines: metrics.NewGauge(),
+	}
+	if err := h.Registry.Register("goroutines-goroutinesCount", h.goroutines); err != nil {
 		log.Fatal(err)
 	}
+	rm.handles[name] = h
+	return h
+}
+
+// Stop flushes metrics last time and stops flushing
+func (rm *RunMonitor) Stop() {
+	if rm == nil {
+		return
+	}
+	rm.stop.Do(func() {
+		close(rm.quit)
+		<-rm.done
+	})
 }
 
-func registerLabelTimings(label string) metrics.Timer {
-	timerMutex.RLock()
-	timer, ok := timers[label]
-	timerMutex.RUnlock()
+func (h *HandleMonitor) registerLabelTimings(label string) metrics.Timer {
+	h.timerMutex.RLock()
+	timer, ok := h.timers[label]
+	h.timerMutex.RUnlock()
 	if ok {
 		return timer
 	}
-	timerMutex.Lock()
-	defer timerMutex.Unlock()
+	h.timerMutex.Lock()
+	defer h.timerMutex.Unlock()
+	if timer, ok = h.timers[label]; ok {
+		return timer
+	}
 	timer = metrics.NewTimer()
-	timers[label] = timer
-	err := metrics.Register(label+"-timer", timer)
+	h.timers[label] = timer
+	err := h.Registry.Register(label+"-timer", timer)
 	if err != nil {
 		log.Println(err)
 	}
 	return timer
 }
 
-func registerErrCount(label string) metrics.Counter {
-	errorMutext.RLock()
-	cnt, ok := errors[label]
-	errorMutext.RUnlock()
+func (h *HandleMonitor) registerErrCount(label string) metrics.Counter {
+	h.errorMutex.RLock()
+	cnt, ok := h.errors[label]
+	h.errorMutex.RUnlock()
 	if ok {
 		return cnt
 	}
-	errorMutext.Lock()
-	defer errorMutext.Unlock()
+	h.errorMutex.Lock()
+	defer h.errorMutex.Unlock()
+	if cnt, ok = h.errors[label]; ok {
+		return cnt
+	}
 	cnt = metrics.NewCounter()
-	errors[label] = cnt
-	err := metrics.Register(label+"-err", cnt)
+	h.errors[label] = cnt
+	err := h.Registry.Register(label+"-err", cnt)
 	if err != nil {
 		log.Fatal(err)
 	}
 	return cnt
 }
 
+func (h *HandleMonitor) addGoroutines(n int64) {
+	h.countMutex.Lock()
+	defer h.countMutex.Unlock()
+	h.goroutinesN += n
+	h.goroutines.Update(h.goroutinesN)
+}
+
+// Goroutines returns number of running monitored attackers
+func (h *HandleMonitor) Goroutines() int64 {
+	h.countMutex.Lock()
+	defer h.countMutex.Unlock()
+	return h.goroutinesN
+}
+
+// monitorState is shared by copies of Monitored value, it is set up once per attacker
+type monitorState struct {
+	h          *HandleMonitor
+	dimensions []string
+}
+
+type Monitored struct {
+	Attack
+	state *monitorState
+}
+
+func WithMonitor(a Attack) Monitored {
+	return Monitored{a, &monitorState{}}
+}
+
 func (m Monitored) Do(ctx context.Context) DoResult {
 	before := time.Now()
 	result := m.Attack.Do(ctx)
-	registerLabelTimings(result.RequestLabel).Update(time.Now().Sub(before))
-	if result.Error != nil || result.StatusCode >= 400 {
-		registerErrCount(result.RequestLabel).Inc(1)
+	if h := m.state.h; h != nil {
+		elapsed := time.Now().Sub(before)
+		failed := result.Error != nil || result.StatusCode >= 400
+		for _, label := range taggedMetricLabels(result, m.state.dimensions) {
+			h.registerLabelTimings(label).Update(elapsed)
+			if failed {
+				h.registerErrCount(label).Inc(1)
+			}
+		}
 	}
 	return result
 }
 
+// taggedMetricLabels returns request label and label for every tag dimension of result,
+// e.g. tags.region.eu.transfer
+func taggedMetricLabels(result DoResult, dimensions []string) []string {
+	labels := []string{result.RequestLabel}
+	for _, dim := range dimensions {
+		value, ok := result.Tags[dim]
+		if !ok {
+			continue
+		}
+		labels = append(labels, strings.Join([]string{"tags", graphiteNode(dim), graphiteNode(value), result.RequestLabel}, "."))
+	}
+	return labels
+}
+
+// graphiteNode makes string safe to be used as one node of graphite metric path
+func graphiteNode(s string) string {
+	return strings.NewReplacer(".", "_", " ", "_", "/", "_").Replace(s)
+}
+
 func (m Monitored) Setup(lm *LoadManager, c Config) error {
 	if err := m.Attack.Setup(lm, c); err != nil {
 		return err
 	}
-	monitorInit.Do(initMonitoring)
+	if lm != nil && m.state.h == nil {
+		m.state.h = lm.Monitor().Handle(c.HandleName)
+		m.state.dimensions = c.TagDimensions
+		m.state.h.addGoroutines(1)
+	}
 	return nil
 }
 
+func (m Monitored) Teardown() error {
+	if m.state.h != nil {
+		m.state.h.addGoroutines(-1)
+		m.state.h = nil
+	}
+	return m.Attack.Teardown()
+}
+
 func (m Monitored) Clone() Attack {
-	goroutinesCount++
-	monitorInit.Do(initMonitoring)
-	gauge.Update(goroutinesCount)
-	return Monitored{m.Attack.Clone()}
+	return Monitored{m.Attack.Clone(), &monitorState{}}
 }
 
 func (m Monitored) PutData(mo interface{}) error {
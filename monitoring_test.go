@@ -0,0 +1,77 @@
+package loadgen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type labeledAttackMock struct {
+	attackMock
+	label string
+}
+
+func (m *labeledAttackMock) Do(ctx context.Context) DoResult {
+	return DoResult{RequestLabel: m.label}
+}
+
+func (m *labeledAttackMock) Clone() Attack {
+	return m
+}
+
+func TestMonitoredHandlesDoNotCollide(t *testing.T) {
+	lm := NewLoadManager()
+	defer lm.Monitor().Stop()
+	a := WithMonitor(&labeledAttackMock{label: "transfer"}).Clone()
+	b := WithMonitor(&labeledAttackMock{label: "transfer"}).Clone()
+	if err := a.Setup(lm, Config{HandleName: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Setup(lm, Config{HandleName: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	a.Do(context.Background())
+	a.Do(context.Background())
+	b.Do(context.Background())
+
+	timerA, ok := lm.Monitor().Registry.Get("a.transfer-timer").(metrics.Timer)
+	if !ok {
+		t.Fatal("expected timer for handle a")
+	}
+	timerB, ok := lm.Monitor().Registry.Get("b.transfer-timer").(metrics.Timer)
+	if !ok {
+		t.Fatal("expected timer for handle b")
+	}
+	if got, want := timerA.Count(), int64(2); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := timerB.Count(), int64(1); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	if got, want := lm.Monitor().Handle("a").Goroutines(), int64(1); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if err := a.Teardown(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lm.Monitor().Handle("a").Goroutines(), int64(0); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestRunMonitorsAreIsolated(t *testing.T) {
+	first := NewLoadManager()
+	second := NewLoadManager()
+	defer first.Monitor().Stop()
+	defer second.Monitor().Stop()
+	a := WithMonitor(&labeledAttackMock{label: "transfer"}).Clone()
+	if err := a.Setup(first, Config{HandleName: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	a.Do(context.Background())
+	if second.Monitor().Registry.Get("a.transfer-timer") != nil {
+		t.Error("expected empty registry for second run")
+	}
+}
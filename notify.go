@@ -0,0 +1,315 @@
+package loadgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	NotifyFormatJSON    = "json"
+	NotifyFormatSlack   = "slack"
+	NotifyFormatDiscord = "discord"
+
+	NotifyOnFinish      = "finish"
+	NotifyOnSuccess     = "success"
+	NotifyOnFail        = "fail"
+	NotifyOnAbort       = "abort"
+	NotifyOnDegradation = "degradation"
+
+	defaultNotifyRetries      = 3
+	defaultNotifyRetryBackoff = 2 * time.Second
+	defaultNotifyTimeout      = 10 * time.Second
+)
+
+var notifyEvents = []string{NotifyOnFinish, NotifyOnSuccess, NotifyOnFail, NotifyOnAbort, NotifyOnDegradation}
+
+const defaultNotifyTemplate = `{{.Suite.Verdict}}: load suite {{.Suite.Suite}} [{{.Suite.Environment}}] {{join .Events ", "}}, {{.Suite.ExecutionMode}} mode{{with .Suite.RunID}}, run {{.}}{{end}}
+{{with .Suite.RunError}}run error: {{.}}
+{{end}}{{range .Suite.Handles}}{{.Name}} {{.Verdict}}{{range .Labels}}, {{.Label}}: {{printf "%.2f" .Rate}} rps, p50 {{.P50}}, p99 {{.P99}}, success {{printf "%.2f" (percent .Success)}}%{{end}}
+{{range .ThresholdViolations}}  threshold {{.}}
+{{end}}{{range .FailedAssertions}}  assertion {{.}}
+{{end}}{{if .RunError}}  run error: {{.RunError}}
+{{end}}{{end}}`
+
+// NotifierConfig is a webhook notified when suite run is finished
+//
+//	notify:
+//	  - url: https://hooks.slack.com/services/T000/B000/XXXX
+//	    format: slack
+//	    on: [fail, abort, degradation]
+//	  - url: https://ci.example.com/load-results
+//	    headers:
+//	      Authorization: Bearer token
+//	    retries: 5
+type NotifierConfig struct {
+	URL string `mapstructure:"url"`
+	// Format is json for Notification body, slack or discord for chat message, default is json
+	Format string `mapstructure:"format"`
+	// On are events notification is sent on, default is finish
+	On []string `mapstructure:"on"`
+	// Template is text/template of chat message or of the whole body for json format, executed with Notification
+	Template     string            `mapstructure:"template"`
+	Headers      map[string]string `mapstructure:"headers"`
+	Retries      int               `mapstructure:"retries"`
+	RetryBackoff time.Duration     `mapstructure:"retry_backoff"`
+	Timeout      time.Duration     `mapstructure:"timeout"`
+}
+
+// Notification is sent to webhooks when suite run is finished
+type Notification struct {
+	// Events are all events of the run, e.g. finish, fail and degradation
+	Events []string     `json:"events"`
+	Suite  *SuiteReport `json:"suite"`
+}
+
+// Notifier posts notifications to webhook
+type Notifier struct {
+	cfg    NotifierConfig
+	tmpl   *template.Template
+	client *http.Client
+	sleep  func(time.Duration)
+}
+
+// NewNotifier checks config and sets defaults
+func NewNotifier(cfg NotifierConfig) (*Notifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("notifier url is empty")
+	}
+	switch cfg.Format {
+	case "":
+		cfg.Format = NotifyFormatJSON
+	case NotifyFormatJSON, NotifyFormatSlack, NotifyFormatDiscord:
+	default:
+		return nil, fmt.Errorf("unknown notifier format [%s], possible values are {json,slack,discord}", cfg.Format)
+	}
+	if len(cfg.On) == 0 {
+		cfg.On = []string{NotifyOnFinish}
+	}
+	for _, e := range cfg.On {
+		if !containsString(notifyEvents, e) {
+			return nil, fmt.Errorf("unknown notifier event [%s], possible values are {%s}", e, strings.Join(notifyEvents, ","))
+		}
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = defaultNotifyRetries
+	}
+	if cfg.RetryBackoff == 0 {
+		cfg.RetryBackoff = defaultNotifyRetryBackoff
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultNotifyTimeout
+	}
+	n := &Notifier{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}, sleep: time.Sleep}
+	text := cfg.Template
+	if text == "" && cfg.Format != NotifyFormatJSON {
+		text = defaultNotifyTemplate
+	}
+	if text != "" {
+		tmpl, err := template.New("notify").Funcs(template.FuncMap{
+			"join": strings.Join,
+			"percent": func(v float64) float64 {
+				return v * 100
+			},
+		}).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("bad notifier template: %s", err)
+		}
+		n.tmpl = tmpl
+	}
+	return n, nil
+}
+
+// NotifiersFromConfig creates notifiers from notify config
+func NotifiersFromConfig() []*Notifier {
+	var cfgs []NotifierConfig
+	if err := viper.UnmarshalKey("notify", &cfgs, viper.DecodeHook(configDecodeHook)); err != nil {
+		log.Fatalf("failed to unmarshal notify config: %s", err)
+	}
+	res := make([]*Notifier, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		n, err := NewNotifier(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		res = append(res, n)
+	}
+	return res
+}
+
+// SuiteEvents returns events of suite run, finish is always there
+func SuiteEvents(s *SuiteReport) []string {
+	events := []string{NotifyOnFinish}
+	if s.Failed || s.AssertionsFailed {
+		events = append(events, NotifyOnFail)
+	}
+	if s.Aborted {
+		events = append(events, NotifyOnAbort)
+	}
+	if s.Degradation {
+		events = append(events, NotifyOnDegradation)
+	}
+	if len(events) == 1 {
+		events = append(events, NotifyOnSuccess)
+	}
+	return events
+}
+
+// Wants returns true if notifier is subscribed to any of events
+func (n *Notifier) Wants(events []string) bool {
+	for _, e := range events {
+		if containsString(n.cfg.On, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Body renders request body in notifier format
+func (n *Notifier) Body(nt Notification) ([]byte, error) {
+	var text bytes.Buffer
+	if n.tmpl != nil {
+		if err := n.tmpl.Execute(&text, nt); err != nil {
+			return nil, err
+		}
+	}
+	switch n.cfg.Format {
+	case NotifyFormatSlack:
+		return json.Marshal(map[string]string{"text": text.String()})
+	case NotifyFormatDiscord:
+		return json.Marshal(map[string]string{"content": text.String()})
+	}
+	if n.tmpl != nil {
+		return text.Bytes(), nil
+	}
+	return json.Marshal(nt)
+}
+
+// Send posts notification, server errors and network failures are retried with growing backoff
+func (n *Notifier) Send(nt Notification) error {
+	body, err := n.Body(nt)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.cfg.Retries {
+			return err
+		}
+		log.Printf("[ notify ] %s, retrying", err)
+		n.sleep(n.cfg.RetryBackoff * time.Duration(attempt+1))
+	}
+}
+
+// host returns scheme and host of webhook url, path and query of webhooks usually contain secret
+func (n *Notifier) host() string {
+	u, err := url.Parse(n.cfg.URL)
+	if err != nil {
+		return "<bad url>"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// post sends body once, returns true if failed request can be retried
+func (n *Notifier) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, n.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("webhook %s: bad url", n.host())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range n.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// url.Error contains the whole webhook url with its secret
+		if ue, ok := err.(*url.Error); ok {
+			return true, fmt.Errorf("webhook %s: %s", n.host(), ue.Err)
+		}
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	err = fmt.Errorf("webhook %s responded %s: %s", n.host(), resp.Status, strings.TrimSpace(string(respBody)))
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+// Notify sends suite report to configured webhooks, failed notifications are logged and do not fail the run
+func (m *LoadManager) Notify() {
+	notifiers := NotifiersFromConfig()
+	if len(notifiers) == 0 {
+		return
+	}
+	if m.SuiteReport == nil {
+		m.SuiteReport = m.NewSuiteReport()
+	}
+	nt := Notification{Events: SuiteEvents(m.SuiteReport), Suite: m.SuiteReport}
+	for _, n := range notifiers {
+		if !n.Wants(nt.Events) {
+			continue
+		}
+		if err := n.Send(nt); err != nil {
+			log.Printf("[ notify ] failed to notify: %s", err)
+		}
+	}
+}
+
+// NotifyFailure sends notification of suite run which can not be finished, e.g. interrupted by signal
+// or stopped by fatal error, it is sent once with reports of finished handles
+func (m *LoadManager) NotifyFailure(reason string) {
+	// handles which are still running change reports and suite status
+	m.CsvMu.Lock()
+	defer m.CsvMu.Unlock()
+	m.notifyFailure(reason)
+}
+
+// notifyFailure sends failure notification, CsvMu must be held
+func (m *LoadManager) notifyFailure(reason string) {
+	m.failureOnce.Do(func() {
+		m.statusMu.Lock()
+		m.Failed = true
+		m.RunError = reason
+		m.statusMu.Unlock()
+		if m.FinishedAt.IsZero() {
+			m.FinishedAt = time.Now()
+		}
+		m.SuiteReport = m.NewSuiteReport()
+		m.Notify()
+	})
+}
+
+// Fatalf logs error, notifies about failed suite run and exits, used for errors the run can not continue with
+func (m *LoadManager) Fatalf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if m == nil {
+		log.Fatal(msg)
+	}
+	log.Print(msg)
+	m.NotifyFailure(msg)
+	os.Exit(1)
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func testSuiteReport() *SuiteReport {
+	lm := NewLoadManager()
+	lm.BaselineKey = BaselineKey{Suite: "prod-min", Environment: "stage"}
+	lm.ExecutionMode = "parallel"
+	r := testRunReport(time.Millisecond)
+	r.Degradation = true
+	lm.Reports["transfer"] = r
+	lm.Degradation = true
+	return lm.NewSuiteReport()
+}
+
+func TestNotifierRetries(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	n, err := NewNotifier(NotifierConfig{URL: srv.URL, Format: NotifyFormatSlack, On: []string{NotifyOnDegradation}, Headers: map[string]string{"X-Token": "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var slept []time.Duration
+	n.sleep = func(d time.Duration) { slept = append(slept, d) }
+	s := testSuiteReport()
+	nt := Notification{Events: SuiteEvents(s), Suite: s}
+	if !n.Wants(nt.Events) {
+		t.Fatal("expected degradation event")
+	}
+	if err := n.Send(nt); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(bodies), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := len(slept), 1; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	var msg map[string]string
+	if err := json.Unmarshal([]byte(bodies[1]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(msg["text"], "DEGRADED: load suite prod-min [stage] finish, degradation") {
+		t.Errorf("unexpected message: %s", msg["text"])
+	}
+
+	// client errors are not retried
+	n, err = NewNotifier(NotifierConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies = nil
+	if err := n.Send(nt); err == nil {
+		t.Error("expected unauthorized error")
+	}
+	if got, want := len(bodies), 1; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	var sent Notification
+	if err := json.Unmarshal([]byte(bodies[0]), &sent); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sent.Suite.Handles[0].Name, "transfer"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestNotifierConfig(t *testing.T) {
+	n, err := NewNotifier(NotifierConfig{URL: "http://hook", Format: NotifyFormatJSON, Template: `{"verdict":"{{.Suite.Verdict}}"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Wants([]string{NotifyOnSuccess}) {
+		t.Error("default notifier is notified on finish only")
+	}
+	body, err := n.Body(Notification{Suite: testSuiteReport()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), `{"verdict":"DEGRADED"}`; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	for _, cfg := range []NotifierConfig{
+		{},
+		{URL: "http://hook", Format: "teams"},
+		{URL: "http://hook", On: []string{"start"}},
+		{URL: "http://hook", Template: "{{"},
+	} {
+		if _, err := NewNotifier(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestNotifierRedactsNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close()
+	n, err := NewNotifier(NotifierConfig{URL: srv.URL + "/services/T000/B000/secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = n.post([]byte("{}"))
+	if err == nil {
+		t.Fatal("expected network error")
+	}
+	if strings.Contains(err.Error(), "secret") || strings.Contains(err.Error(), "services") {
+		t.Errorf("webhook secret in error: %s", err)
+	}
+	if !strings.Contains(err.Error(), srv.URL) {
+		t.Errorf("expected webhook host in error: %s", err)
+	}
+}
+
+func TestNotifyFailure(t *testing.T) {
+	defer viper.Reset()
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+	}))
+	defer srv.Close()
+	viper.Set("notify", []map[string]interface{}{{"url": srv.URL, "on": []string{NotifyOnFail}}})
+	lm := NewLoadManager()
+	lm.Reports["transfer"] = testRunReport(time.Millisecond)
+	lm.NotifyFailure("interrupted by terminated signal")
+	lm.NotifyFailure("BeforeRun failed")
+	if got, want := len(bodies), 1; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	var nt Notification
+	if err := json.Unmarshal([]byte(bodies[0]), &nt); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := nt.Suite.RunError, "interrupted by terminated signal"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := nt.Suite.Verdict, "FAILED"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+package loadgen
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// SuiteOverrides are command line changes of suite config applied before runners are built,
+// e.g. -set handles.transfer.rps=50 -set checks.handle_threshold_percent=1.3 -rps-scale 0.5
+type SuiteOverrides struct {
+	// Set are key=value overrides, handles are addressed by name: handles.<name>.<key>
+	Set []string
+	// RPSScale multiplies rps of every handle before Set overrides are applied, zero means no scaling
+	RPSScale float64
+}
+
+// setFlag is a repeatable key=value flag
+type setFlag []string
+
+func (f *setFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *setFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// RegisterFlags registers -set and -rps-scale flags in flag set
+func (o *SuiteOverrides) RegisterFlags(fs *flag.FlagSet) {
+	fs.Var((*setFlag)(&o.Set), "set", "override suite config value, e.g. -set handles.transfer.rps=50 -set checks.handle_threshold_percent=1.3, can be repeated")
+	fs.Float64Var(&o.RPSScale, "rps-scale", 0, "multiply rps of every handle, e.g. 0.1 for smoke or 2 for peak run")
+}
+
+// Apply changes config read into v, problems are returned with keys of bad overrides
+func (o SuiteOverrides) Apply(v *viper.Viper) []ConfigProblem {
+	var problems []ConfigProblem
+	if o.RPSScale < 0 {
+		problems = append(problems, ConfigProblem{Path: "rps-scale", Message: "must be a positive number"})
+	} else if o.RPSScale > 0 && o.RPSScale != 1 {
+		handles := configHandles(v)
+		for i, h := range handles {
+			rps, err := ParseRate(fmt.Sprint(h["rps"]))
+			if err != nil {
+				problems = append(problems, ConfigProblem{Path: fmt.Sprintf("handles[%d].rps", i), Message: err.Error()})
+				continue
+			}
+			scaled := int(math.Max(1, math.Round(float64(rps)*o.RPSScale)))
+			log.Printf("[ %s ] rps scaled by %g: %d -> %d", h["name"], o.RPSScale, rps, scaled)
+			h["rps"] = scaled
+		}
+		setConfigHandles(v, handles)
+	}
+	schema := suiteSchema()
+	for _, s := range o.Set {
+		kv := strings.SplitN(s, "=", 2)
+		key := normalizeOverrideKey(kv[0])
+		if len(kv) != 2 || key == "" {
+			problems = append(problems, ConfigProblem{Path: s, Message: "-set override must be key=value"})
+			continue
+		}
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(kv[1]), &value); err != nil {
+			problems = append(problems, ConfigProblem{Path: key, Message: fmt.Sprintf("-set override value: %s", err)})
+			continue
+		}
+		if msg := schema.overrideKey(key); msg != "" {
+			problems = append(problems, ConfigProblem{Path: key, Message: "-set override " + msg})
+			continue
+		}
+		if msg := setConfigValue(v, key, value); msg != "" {
+			problems = append(problems, ConfigProblem{Path: key, Message: "-set override " + msg})
+			continue
+		}
+		log.Printf("[ config ] override %s=%s", key, kv[1])
+	}
+	return problems
+}
+
+// normalizeOverrideKey lowercases setting path of -set key, handle name of handles.<name>.<key> is kept as is
+func normalizeOverrideKey(key string) string {
+	parts := strings.Split(strings.TrimSpace(key), ".")
+	for i, p := range parts {
+		if i == 1 && parts[0] == "handles" {
+			continue
+		}
+		parts[i] = strings.ToLower(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// overrideKey checks dotted key against schema, handles are addressed by name, returns a problem or empty string
+func (s *configSchema) overrideKey(key string) string {
+	parts := strings.Split(key, ".")
+	if parts[0] == "handles" {
+		if len(parts) < 3 {
+			return "of handle must be handles.<name>.<key>"
+		}
+		s, parts = s.keys["handles"].items, parts[2:]
+	}
+	for _, p := range parts {
+		if s.free {
+			return ""
+		}
+		child, ok := s.keys[p]
+		if !ok {
+			msg := fmt.Sprintf("unknown key %q", p)
+			if suggestion := s.suggestKey(p); suggestion != "" {
+				msg += fmt.Sprintf(", did you mean %q?", suggestion)
+			}
+			return msg
+		}
+		s = child
+	}
+	return ""
+}
+
+// setConfigValue sets dotted key of config, handles.<name>.<key> sets key of named handle
+func setConfigValue(v *viper.Viper, key string, value interface{}) string {
+	parts := strings.SplitN(key, ".", 3)
+	if parts[0] != "handles" {
+		v.Set(key, value)
+		return ""
+	}
+	handles := configHandles(v)
+	for _, h := range handles {
+		if fmt.Sprint(h["name"]) == parts[1] {
+			setNested(h, strings.Split(parts[2], "."), value)
+			setConfigHandles(v, handles)
+			return ""
+		}
+	}
+	return fmt.Sprintf("has no handle named [%s]", parts[1])
+}
+
+func setNested(m map[string]interface{}, path []string, value interface{}) {
+	if len(path) == 1 {
+		m[path[0]] = value
+		return
+	}
+	child, ok := m[path[0]].(map[string]interface{})
+	if !ok {
+		child = map[string]interface{}{}
+		switch c := m[path[0]].(type) {
+		case map[interface{}]interface{}:
+			for k, v := range c {
+				child[strings.ToLower(fmt.Sprint(k))] = v
+			}
+		}
+		m[path[0]] = child
+	}
+	setNested(child, path[1:], value)
+}
+
+// configHandles returns copies of handle settings with lowercase keys as viper keeps them
+func configHandles(v *viper.Viper) []map[string]interface{} {
+	list, _ := v.Get("handles").([]interface{})
+	res := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		h := map[string]interface{}{}
+		switch item := item.(type) {
+		case map[string]interface{}:
+			for k, v := range item {
+				h[strings.ToLower(k)] = v
+			}
+		case map[interface{}]interface{}:
+			for k, v := range item {
+				h[strings.ToLower(fmt.Sprint(k))] = v
+			}
+		}
+		res = append(res, h)
+	}
+	return res
+}
+
+func setConfigHandles(v *viper.Viper, handles []map[string]interface{}) {
+	list := make([]interface{}, len(handles))
+	for i, h := range handles {
+		list[i] = h
+	}
+	v.Set("handles", list)
+}
@@ -0,0 +1,85 @@
+package loadgen
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestSuiteOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer viper.Reset()
+
+	p := writeTestConfig(t, dir, "peak.yaml", `execution_mode: parallel
+checks:
+  handle_threshold_percent: 1.2
+defaults:
+  attack_time: 1m
+  ramp_up: 10s
+  max_attackers: 10
+  do_timeout: 5s
+handles:
+  - name: transfer
+    rps: 300/m
+  - name: balance
+    rps: 30
+  - name: createMember
+    rps: 10
+`)
+	o := SuiteOverrides{
+		Set:      []string{"handles.transfer.rps=50", "checks.handle_threshold_percent=1.3", "handles.balance.metadata.region=eu", "Handles.createMember.RPS=20"},
+		RPSScale: 0.5,
+	}
+	if problems := ValidateOverriddenSuiteConfigFile(p, o); len(problems) > 0 {
+		t.Fatal(problems)
+	}
+	var cfg SuiteConfig
+	if err := viper.Unmarshal(&cfg, viper.DecodeHook(configDecodeHook)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.Handles[0].RPS, 50; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := cfg.Handles[1].RPS, 15; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := cfg.Handles[2].RPS, 20; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := cfg.Handles[1].AttackTimeSec, 60; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := cfg.Handles[1].Metadata["region"], "eu"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := viper.GetFloat64("checks.handle_threshold_percent"), 1.3; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	o = SuiteOverrides{Set: []string{"handles.transfer.rpss=50", "handles.deposit.rps=5", "checks.baseline_run=5", "rps"}}
+	var got []string
+	for _, p := range ValidateOverriddenSuiteConfigFile(p, o) {
+		got = append(got, p.Path+": "+p.Message)
+	}
+	want := []string{
+		`handles.transfer.rpss: -set override unknown key "rpss"`,
+		`handles.deposit.rps: -set override has no handle named [deposit]`,
+		`checks.baseline_run: -set override unknown key "baseline_run"`,
+		`rps: -set override must be key=value`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("got %v want %v", got[i], want[i])
+		}
+	}
+}
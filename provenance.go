@@ -0,0 +1,384 @@
+package loadgen
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// RunIDEnv overrides generated run id, e.g. with CI job id
+	RunIDEnv = "LOADGEN_RUN_ID"
+	// GitCommitEnv is used as git commit of load scripts when git is not available
+	GitCommitEnv = "GIT_COMMIT"
+
+	loadgenModule     = "github.com/skudasov/loadgenold"
+	defaultRedactMask = "***---***---***"
+)
+
+// Version is loadgen version, set with -ldflags "-X github.com/skudasov/loadgenold.Version=v1.2.3",
+// module version from build info is used when empty
+var Version = ""
+
+// default key patterns of secrets, matched case insensitive against setting key or metadata key
+var defaultRedactKeys = []string{`passw(or)?d`, `^pass$`, `secret`, `token`, `api_?key`, `access_?key`, `private`, `credential`, `auth`, `rootkeys`}
+
+// settings sections with urls which carry secrets in path or query, e.g. slack and discord webhooks
+var secretURLSettings = []string{"notify"}
+
+// infrastructure settings of loadgen itself, their urls are not targets
+var nonTargetSettings = []string{"grafana.", "graphite.", "prometheus.", "reports.", "tracing.", "notify."}
+
+// Provenance describes where and what was tested
+type Provenance struct {
+	RunID          string `json:"runId"`
+	Hostname       string `json:"hostname"`
+	GoVersion      string `json:"goVersion"`
+	LoadgenVersion string `json:"loadgenVersion"`
+	// GitCommit is a commit of the load scripts repo, "-dirty" suffix is added when there are local changes
+	GitCommit  string `json:"gitCommit,omitempty"`
+	ConfigFile string `json:"configFile,omitempty"`
+	// ConfigHash is sha256 of suite config file content
+	ConfigHash string `json:"configHash,omitempty"`
+	// Settings are resolved viper settings with secrets redacted
+	Settings map[string]interface{} `json:"settings,omitempty"`
+	// Targets are urls of tested system
+	Targets []string `json:"targets,omitempty"`
+}
+
+// NewProvenance collects provenance of current process and suite config, secrets are redacted by policy
+func NewProvenance(cfgFile string, policy *RedactionPolicy) *Provenance {
+	p := &Provenance{
+		RunID:          newRunID(),
+		GoVersion:      runtime.Version(),
+		LoadgenVersion: loadgenVersion(),
+		ConfigFile:     cfgFile,
+		Settings:       policy.RedactSettings(viper.AllSettings()),
+		Targets:        targetURLs(policy),
+	}
+	var err error
+	if p.Hostname, err = os.Hostname(); err != nil {
+		log.Printf("failed to get hostname: %s", err)
+	}
+	dir := "."
+	if cfgFile != "" {
+		if p.ConfigFile, err = filepath.Abs(cfgFile); err != nil {
+			p.ConfigFile = cfgFile
+		}
+		dir = filepath.Dir(p.ConfigFile)
+		if data, err := ioutil.ReadFile(cfgFile); err == nil {
+			p.ConfigHash = sha256Hex(data)
+		}
+	}
+	p.GitCommit = gitCommit(dir)
+	return p
+}
+
+func newRunID() string {
+	if id := os.Getenv(RunIDEnv); id != "" {
+		return id
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func loadgenVersion() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == loadgenModule {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == loadgenModule {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+// gitCommit returns HEAD of git repo containing dir
+func gitCommit(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return os.Getenv(GitCommitEnv)
+	}
+	commit := strings.TrimSpace(string(out))
+	if status, err := exec.Command("git", "-C", dir, "status", "--porcelain", "--untracked-files=no").Output(); err == nil && len(status) > 0 {
+		commit += "-dirty"
+	}
+	return commit
+}
+
+// targetURLs returns targets setting or all http urls found in settings except loadgen infrastructure ones
+func targetURLs(policy *RedactionPolicy) []string {
+	if targets := viper.GetStringSlice("targets"); len(targets) > 0 {
+		res := make([]string, 0, len(targets))
+		for _, t := range targets {
+			res = append(res, policy.RedactValue(t))
+		}
+		return res
+	}
+	seen := map[string]bool{}
+	var res []string
+	for _, key := range viper.AllKeys() {
+		if hasAnyPrefix(key, nonTargetSettings) {
+			continue
+		}
+		for _, v := range settingStrings(viper.Get(key)) {
+			if !strings.HasPrefix(v, "http://") && !strings.HasPrefix(v, "https://") {
+				continue
+			}
+			if v = policy.RedactValue(v); !seen[v] {
+				seen[v] = true
+				res = append(res, v)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func settingStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		var res []string
+		for _, e := range v {
+			res = append(res, settingStrings(e)...)
+		}
+		return res
+	case map[string]interface{}:
+		var res []string
+		for _, e := range v {
+			res = append(res, settingStrings(e)...)
+		}
+		return res
+	case map[interface{}]interface{}:
+		var res []string
+		for _, e := range v {
+			res = append(res, settingStrings(e)...)
+		}
+		return res
+	}
+	return nil
+}
+
+// RedactionPolicy masks secrets in reports: values of keys matching key patterns, parts of values matching
+// value patterns, passwords of urls and metadata keys ending with *
+type RedactionPolicy struct {
+	Keys   []*regexp.Regexp
+	Values []*regexp.Regexp
+	Mask   string
+}
+
+// NewRedactionPolicy compiles case insensitive key patterns and value patterns, default mask is used when empty
+func NewRedactionPolicy(keys, values []string, mask string) (*RedactionPolicy, error) {
+	p := &RedactionPolicy{Mask: mask}
+	if p.Mask == "" {
+		p.Mask = defaultRedactMask
+	}
+	for _, k := range keys {
+		re, err := regexp.Compile("(?i)" + k)
+		if err != nil {
+			return nil, fmt.Errorf("bad redaction key pattern %q: %s", k, err)
+		}
+		p.Keys = append(p.Keys, re)
+	}
+	for _, v := range values {
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("bad redaction value pattern %q: %s", v, err)
+		}
+		p.Values = append(p.Values, re)
+	}
+	return p, nil
+}
+
+// RedactionPolicyFromConfig creates policy from redaction config, default key patterns are kept
+// unless redaction.replace_defaults is set
+//
+//	redaction:
+//	  keys: [session, "^x-"]
+//	  values: ["eyJ[a-zA-Z0-9_.-]+"]
+//	  mask: "<hidden>"
+func RedactionPolicyFromConfig() *RedactionPolicy {
+	keys := viper.GetStringSlice("redaction.keys")
+	if !viper.GetBool("redaction.replace_defaults") {
+		keys = append(append([]string{}, defaultRedactKeys...), keys...)
+	}
+	p, err := NewRedactionPolicy(keys, viper.GetStringSlice("redaction.values"), viper.GetString("redaction.mask"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return p
+}
+
+// secretKey reports whether values of key must be masked
+func (p *RedactionPolicy) secretKey(key string) bool {
+	if strings.HasSuffix(key, "*") {
+		return true
+	}
+	for _, re := range p.Keys {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns masked value when key is secret, otherwise value with secret parts masked
+func (p *RedactionPolicy) Redact(key, value string) string {
+	if p.secretKey(key) {
+		return p.Mask
+	}
+	return p.RedactValue(value)
+}
+
+// RedactValue masks parts of value matching value patterns and password of url
+func (p *RedactionPolicy) RedactValue(value string) string {
+	for _, re := range p.Values {
+		value = re.ReplaceAllString(value, p.Mask)
+	}
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			value = strings.Replace(value, u.User.String()+"@", u.User.Username()+":"+p.Mask+"@", 1)
+		}
+	}
+	return value
+}
+
+// RedactURL masks path and query of url, only scheme and host are kept, non-url value is returned as is
+func (p *RedactionPolicy) RedactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return value
+	}
+	if strings.Trim(u.Path, "/") == "" && u.RawQuery == "" {
+		return u.Scheme + "://" + u.Host
+	}
+	return u.Scheme + "://" + u.Host + "/" + p.Mask
+}
+
+// RedactMap returns a copy of string map with secrets masked
+func (p *RedactionPolicy) RedactMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[k] = p.Redact(k, v)
+	}
+	return res
+}
+
+// RedactSettings returns a deep copy of nested settings with secrets masked
+func (p *RedactionPolicy) RedactSettings(settings map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		if p.secretKey(k) {
+			res[k] = p.Mask
+			continue
+		}
+		res[k] = p.redactSetting(v)
+		for _, section := range secretURLSettings {
+			if strings.EqualFold(k, section) {
+				res[k] = p.redactURLs(res[k])
+			}
+		}
+	}
+	return res
+}
+
+// redactURLs masks paths of urls in redacted settings
+func (p *RedactionPolicy) redactURLs(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return p.RedactURL(v)
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = p.redactURLs(e)
+		}
+	case map[string]string:
+		for k, e := range v {
+			v[k] = p.RedactURL(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = p.redactURLs(e)
+		}
+	case []string:
+		for i, e := range v {
+			v[i] = p.RedactURL(e)
+		}
+	}
+	return v
+}
+
+func (p *RedactionPolicy) redactSetting(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return p.RedactValue(v)
+	case map[string]interface{}:
+		return p.RedactSettings(v)
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = e
+		}
+		return p.RedactSettings(m)
+	case map[string]string:
+		return p.RedactMap(v)
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			res[i] = p.redactSetting(e)
+		}
+		return res
+	case []map[string]interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			res[i] = p.RedactSettings(e)
+		}
+		return res
+	case []string:
+		res := make([]string, len(v))
+		for i, e := range v {
+			res[i] = p.RedactValue(e)
+		}
+		return res
+	}
+	return v
+}
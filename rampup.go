@@ -18,7 +18,7 @@ type linearIncreasingGoroutinesAndRequestsPerSecondStrategy struct{}
 
 func (s linearIncreasingGoroutinesAndRequestsPerSecondStrategy) execute(r *Runner) {
 	r.spawnAttacker()
-	for i := 1; i <= r.config.RampUpTimeSec; i++ {
+	for i := 1; i <= r.config.RampUpTimeSec && !r.m.IsAborted(); i++ {
 		spawnAttackersToSize(r, i*r.config.MaxAttackers/r.config.RampUpTimeSec)
 		takeDuringOneRampupSecond(r, i)
 	}
@@ -37,6 +37,7 @@ func spawnAttackersToSize(r *Runner, count int) {
 
 // takeDuringOneRampupSecond puts all attackers to work during one second with a reduced RPS.
 func takeDuringOneRampupSecond(r *Runner, second int) (int, *Metrics) {
+	r.applyReload()
 	// collect metrics for each second
 	rampMetrics := new(Metrics)
 	// rampup can only proceed when at least one attacker is waiting for rps tokens
@@ -45,23 +46,28 @@ func takeDuringOneRampupSecond(r *Runner, second int) (int, *Metrics) {
 		return 0, rampMetrics
 	}
 	// change pipeline function to collect local metrics
-	r.resultsPipeline = func(rs result) result {
+	r.setResultsPipeline(func(rs result) result {
 		rampMetrics.add(rs)
 		return rs
-	}
+	})
 	// for each second start a new reduced rate limiter
 	rps := second * r.config.RPS / r.config.RampUpTimeSec
 	if rps == 0 { // minimal 1
 		rps = 1
 	}
 	limiter := ratelimit.New(rps)
+	r.live.setTargetRPS(rps)
 	oneSecondAhead := time.Now().Add(1 * time.Second)
 	// put the attackers to work
-	for time.Now().Before(oneSecondAhead) {
+	for time.Now().Before(oneSecondAhead) && !r.m.IsAborted() {
 		limiter.Take()
 		r.next <- true
 	}
 	limiter.Take() // to compensate for the first Take of the new limiter
+	// results of the second still in flight are dropped, rampMetrics is not written after the swap
+	r.setResultsPipeline(func(rs result) result {
+		return rs
+	})
 	rampMetrics.updateLatencies()
 
 	if r.config.Verbose {
@@ -75,7 +81,7 @@ type spawnAsWeNeedStrategy struct{}
 
 func (s spawnAsWeNeedStrategy) execute(r *Runner) {
 	r.spawnAttacker() // start at least one
-	for i := 1; i <= r.config.RampUpTimeSec; i++ {
+	for i := 1; i <= r.config.RampUpTimeSec && !r.m.IsAborted(); i++ {
 		targetRate, lastMetrics := takeDuringOneRampupSecond(r, i)
 		currentRate := lastMetrics.Rate
 		if currentRate < float64(targetRate) {
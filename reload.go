@@ -0,0 +1,260 @@
+package loadgen
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// reloadDebounce waits for editors to finish writing config before it is reloaded
+const reloadDebounce = 200 * time.Millisecond
+
+// reloadableKeys are handle settings applied during run when suite config is changed
+var reloadableKeys = []string{"rps", "max_attackers", "thresholds", "verbose"}
+
+// ConfigChange is a handle setting changed by suite config reload during run
+type ConfigChange struct {
+	Key  string    `json:"key"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+func (c ConfigChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Key, c.From, c.To)
+}
+
+// diffConfig returns changes of reloadable settings and keys of other changed settings
+func diffConfig(from, to Config, at time.Time) ([]ConfigChange, []string) {
+	var changes []ConfigChange
+	var ignored []string
+	t := reflect.TypeOf(Config{})
+	fv, tv := reflect.ValueOf(from), reflect.ValueOf(to)
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("mapstructure"), ",")[0]
+		a, b := fv.Field(i).Interface(), tv.Field(i).Interface()
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		if !containsString(reloadableKeys, key) {
+			ignored = append(ignored, key)
+			continue
+		}
+		changes = append(changes, ConfigChange{Key: key, From: configValueString(a), To: configValueString(b), At: at})
+	}
+	return changes, ignored
+}
+
+func configValueString(v interface{}) string {
+	ts, ok := v.([]Threshold)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	res := make([]string, len(ts))
+	for i, t := range ts {
+		res[i] = t.String()
+	}
+	return "[" + strings.Join(res, "; ") + "]"
+}
+
+// Reload schedules safe changes of handle config: rps, max attackers, thresholds and verbosity,
+// they are applied and recorded by running handle, returns scheduled changes and keys of other changed settings which require restart
+func (r *Runner) Reload(c Config) ([]ConfigChange, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return nil, nil
+	}
+	changes, ignored := diffConfig(r.desired, c, time.Now())
+	if len(changes) == 0 {
+		return nil, ignored
+	}
+	r.desired.RPS = c.RPS
+	r.desired.MaxAttackers = c.MaxAttackers
+	r.desired.Thresholds = c.Thresholds
+	r.desired.Verbose = c.Verbose
+	desired := r.desired
+	r.pending = &desired
+	return changes, ignored
+}
+
+// applyReload applies scheduled config changes in runner goroutine and records them, returns true if target rps is changed
+func (r *Runner) applyReload() bool {
+	r.mu.Lock()
+	c := r.pending
+	r.pending = nil
+	if c == nil {
+		r.mu.Unlock()
+		return false
+	}
+	changes, _ := diffConfig(r.config, *c, time.Now())
+	r.changes = append(r.changes, changes...)
+	r.mu.Unlock()
+	for _, ch := range changes {
+		log.Printf("[ %s ] config reloaded, %s", r.name, ch)
+	}
+	rpsChanged := c.RPS != r.config.RPS
+	r.config.RPS = c.RPS
+	r.config.Verbose = c.Verbose
+	r.config.Thresholds = c.Thresholds
+	if c.MaxAttackers != r.config.MaxAttackers {
+		r.config.MaxAttackers = c.MaxAttackers
+		r.resizeAttackers()
+	}
+	if r.slo == nil && newSLOMonitor(r.name, r.config) != nil {
+		log.Printf("[ %s ] SLO assertions can not be added to handle without assertions during run, they are checked on the next run", r.name)
+	}
+	r.slo.reconfigure(r.config)
+	return rpsChanged
+}
+
+// resizeAttackers stops attackers above max attackers, during full attack new attackers are spawned up to it
+func (r *Runner) resizeAttackers() {
+	for len(r.attackers) > r.config.MaxAttackers && len(r.attackers) > 1 {
+		last := len(r.attackers) - 1
+		r.quits[last] <- true
+		r.retired = append(r.retired, r.attackers[last])
+		r.attackers = r.attackers[:last]
+		r.quits = r.quits[:last]
+	}
+	if !r.fullAttackStartedAt.IsZero() {
+		spawnAttackersToSize(r, r.config.MaxAttackers)
+	}
+	r.live.setAttackers(len(r.attackers))
+}
+
+// finishReload stops accepting config changes and returns changes applied during run
+func (r *Runner) finishReload() []ConfigChange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finished = true
+	return r.changes
+}
+
+// ReloadSuiteConfig reads suite config file again with command line overrides and applies safe changes
+// of handles to runners, config with problems is rejected
+func (m *LoadManager) ReloadSuiteConfig(path string) error {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if isYAMLFile(path) {
+		cfg, err := composeSuiteConfig(path)
+		if err != nil {
+			return err
+		}
+		if err := cfg.readInto(v); err != nil {
+			return err
+		}
+	} else if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+	if problems := suiteOverrides.Apply(v); len(problems) > 0 {
+		return problems[0]
+	}
+	var suiteCfg SuiteConfig
+	if err := v.Unmarshal(&suiteCfg, viper.DecodeHook(configDecodeHook)); err != nil {
+		return err
+	}
+	configs := map[string]Config{}
+	for _, c := range suiteCfg.HandleConfigs() {
+		if msgs := c.Validate(); len(msgs) > 0 {
+			return fmt.Errorf("handle [%s]: %s", c.HandleName, strings.Join(msgs, ", "))
+		}
+		configs[c.HandleName] = c
+	}
+	for _, r := range m.Groups {
+		c, ok := configs[r.name]
+		if !ok {
+			log.Printf("[ %s ] handle is removed from config, it keeps running until restart", r.name)
+			continue
+		}
+		delete(configs, r.name)
+		changes, ignored := r.Reload(c)
+		for _, ch := range changes {
+			log.Printf("[ %s ] config change scheduled, %s", r.name, ch)
+		}
+		if len(ignored) > 0 {
+			log.Printf("[ %s ] changes of %s require restart, ignored", r.name, strings.Join(ignored, ", "))
+		}
+	}
+	for name := range configs {
+		log.Printf("[ %s ] new handle requires restart, ignored", name)
+	}
+	return nil
+}
+
+// WatchSuiteConfig reloads suite config on change of any of its files, enabled by hot_reload: true,
+// returned func stops watching
+func (m *LoadManager) WatchSuiteConfig() func() {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return func() {}
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("[ config ] failed to watch suite config: %s", err)
+		return func() {}
+	}
+	files := map[string]bool{}
+	// directories are watched because editors replace files on save
+	watch := func() {
+		sources := []string{path}
+		if isYAMLFile(path) {
+			if cfg, err := composeSuiteConfig(path); err == nil {
+				sources = sources[:0]
+				for _, s := range cfg.sources {
+					sources = append(sources, s.path)
+				}
+			}
+		}
+		for _, s := range sources {
+			abs, err := filepath.Abs(s)
+			if err != nil || files[abs] {
+				continue
+			}
+			files[abs] = true
+			if err := watcher.Add(filepath.Dir(abs)); err != nil {
+				log.Printf("[ config ] failed to watch %s: %s", s, err)
+			}
+		}
+	}
+	watch()
+	log.Printf("[ config ] watching %s for changes", path)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var reload <-chan time.Time
+		for {
+			select {
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if files[filepath.Clean(e.Name)] && e.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					reload = time.After(reloadDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("[ config ] watch error: %s", err)
+			case <-reload:
+				reload = nil
+				if err := m.ReloadSuiteConfig(path); err != nil {
+					log.Printf("[ config ] reload rejected: %s", err)
+					continue
+				}
+				watch()
+			}
+		}
+	}()
+	return func() {
+		watcher.Close()
+		<-done
+	}
+}
@@ -0,0 +1,126 @@
+package loadgen
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReloadSuiteConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lm := NewLoadManager()
+	c := Config{HandleName: "transfer", RPS: 10, AttackTimeSec: 60, RampUpTimeSec: 10, MaxAttackers: 10, DoTimeoutSec: 5,
+		Thresholds: []Threshold{{Expr: "rate >= 0.9*target"}}}
+	r := NewRunner("transfer", lm, &attackMock{}, c)
+	lm.Groups = append(lm.Groups, r)
+
+	p := writeTestConfig(t, dir, "explore.yaml", `execution_mode: parallel
+hot_reload: true
+handles:
+  - name: transfer
+    rps: 20
+    attack_time: 2m
+    ramp_up_sec: 10
+    max_attackers: 10
+    do_timeout_sec: 5
+    thresholds:
+      - rate >= 0.9*target
+      - p99 < 300ms
+`)
+	if err := lm.ReloadSuiteConfig(p); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(r.changes), 0; got != want {
+		t.Errorf("changes are recorded when applied: got %v want %v", got, want)
+	}
+	if r.applyReload() != true {
+		t.Error("expected rps change")
+	}
+	if got, want := r.config.RPS, 20; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := r.config.AttackTimeSec, 60; got != want {
+		t.Errorf("attack time requires restart: got %v want %v", got, want)
+	}
+	if got, want := len(r.slo.assertions), 2; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := r.slo.targetRPS, 20; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	changes := r.finishReload()
+	if got, want := len(changes), 2; got != want {
+		t.Fatalf("got %v want %v", changes, want)
+	}
+	if got, want := changes[0].String(), "rps: 10 -> 20"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := changes[1].String(), "thresholds: [rate >= 0.9*target] -> [rate >= 0.9*target; p99 < 300ms]"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	p = writeTestConfig(t, dir, "bad.yaml", `execution_mode: parallel
+handles:
+  - name: transfer
+    rps: 0
+    attack_time_sec: 60
+    ramp_up_sec: 10
+    max_attackers: 10
+    do_timeout_sec: 5
+`)
+	if err := lm.ReloadSuiteConfig(p); err == nil {
+		t.Error("expected rejected config")
+	}
+}
+
+func TestRunnerReloadDuringRun(t *testing.T) {
+	lm := NewLoadManager()
+	c := Config{RPS: 5, AttackTimeSec: 2, RampUpTimeSec: 1, MaxAttackers: 2, DoTimeoutSec: 1}
+	r := NewRunner("transfer", lm, &attackMock{}, c)
+	go func() {
+		time.Sleep(1200 * time.Millisecond)
+		reloaded := c
+		reloaded.RPS = 10
+		reloaded.MaxAttackers = 3
+		r.Reload(reloaded)
+	}()
+	r.Run(nil, lm)
+	report := lm.Reports["transfer"]
+	if got, want := len(report.ConfigChanges), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := report.Configuration.RPS, 10; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := len(r.attackers), 3; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if changes, _ := r.Reload(c); len(changes) > 0 {
+		t.Error("finished handle can not be reloaded")
+	}
+}
+
+func TestResizeAttackersStopsLast(t *testing.T) {
+	r := &Runner{config: Config{MaxAttackers: 2}, live: newLiveStats()}
+	for i := 0; i < 3; i++ {
+		r.attackers = append(r.attackers, &attackMock{})
+		r.quits = append(r.quits, make(chan bool, 1))
+	}
+	last := r.quits[2]
+	r.resizeAttackers()
+	if got, want := len(last), 1; got != want {
+		t.Errorf("last attacker is not stopped: got %v want %v", got, want)
+	}
+	if got, want := len(r.quits[0])+len(r.quits[1]), 0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := len(r.retired), 1; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"io"
 	"log"
 	"os"
-	"strings"
 	"time"
 )
 
@@ -27,6 +26,9 @@ type DoResult struct {
 	BytesIn int64
 	// Number of bytes transferred when receiving the response.
 	BytesOut int64
+	// Tags are dimensions of the request, e.g. region, tenant or retry attempt.
+	// Metrics are broken down by tags listed in tag_dimensions config.
+	Tags map[string]string
 }
 
 // RunReport is a composition of configuration, measurements and custom output from a load Run.
@@ -41,6 +43,23 @@ type RunReport struct {
 	Failed bool `json:"failed"`
 	// Output is used to publish any custom output in the report.
 	Output map[string]interface{} `json:"output"`
+	// GuardBreaches are target-side guard breaches happened during the Run.
+	GuardBreaches []GuardBreach `json:"guardBreaches,omitempty"`
+	// Degradation is set when the handle is slower than in the last successful run.
+	Degradation      bool              `json:"degradation"`
+	DegradationRatio float64           `json:"degradationRatio,omitempty"`
+	DegradationCheck *DegradationCheck `json:"degradationCheck,omitempty"`
+	// ThresholdViolations are metrics which reached configured thresholds.
+	ThresholdViolations []ThresholdViolation `json:"thresholdViolations,omitempty"`
+	// Assertions are results of SLO assertions, Failed is set when any of them failed.
+	Assertions []AssertionResult `json:"assertions,omitempty"`
+	// Unreliable is set when the load generator itself was the bottleneck.
+	Unreliable      bool             `json:"unreliable"`
+	GeneratorHealth *GeneratorHealth `json:"generatorHealth,omitempty"`
+	// Provenance describes where and what was tested, set for suite runs
+	Provenance *Provenance `json:"provenance,omitempty"`
+	// ConfigChanges are handle settings changed by suite config reload during run
+	ConfigChanges []ConfigChange `json:"configChanges,omitempty"`
 }
 
 // NewErrorReport returns a report when a Run could not be called or executed.
@@ -57,12 +76,7 @@ func NewErrorReport(err error, config Config) RunReport {
 
 // PrintReport writes the JSON report to a file or stdout, depending on the configuration.
 func PrintReport(r RunReport) {
-	// make secrets in Metadata unreadable
-	for k := range r.Configuration.Metadata {
-		if strings.HasSuffix(k, "*") {
-			r.Configuration.Metadata[k] = "***---***---***"
-		}
-	}
+	r.Configuration = redactedConfig(r.Configuration)
 	var out io.Writer
 	if len(r.Configuration.OutputFilename) > 0 {
 		file, err := os.Create(r.Configuration.OutputFilename)
@@ -81,3 +95,16 @@ func PrintReport(r RunReport) {
 		os.Stdout.Write(data)
 	}
 }
+
+// maskedMetadata returns a copy of Metadata with secrets made unreadable by redaction policy
+func maskedMetadata(md map[string]string) map[string]string {
+	return RedactionPolicyFromConfig().RedactMap(md)
+}
+
+// redactedConfig returns a copy of Config with secrets of metadata and handle params made unreadable
+func redactedConfig(c Config) Config {
+	p := RedactionPolicyFromConfig()
+	c.Metadata = p.RedactMap(c.Metadata)
+	c.HandleParams = p.RedactMap(c.HandleParams)
+	return c
+}
@@ -21,25 +21,50 @@ type BeforeRunner interface {
 
 // AfterRunner can be implemented by an Attacker
 // and its method is called after a test or Run.
-// The report is passed to compute the Failed field and/or store values in Output.
+// The report with metrics, timing and configuration of the Run is passed to compute
+// the Failed field and/or store values in Output, changes are kept in the stored report.
 type AfterRunner interface {
 	AfterRun(r *RunReport) error
 }
 
 type Runner struct {
-	name            string
-	ReadCsvName     string
-	WriteCsvName    string
-	RecycleData     bool
-	sequence        int
-	m               *LoadManager
-	config          Config
-	attackers       []Attack
-	next, quit      chan bool
+	name         string
+	ReadCsvName  string
+	WriteCsvName string
+	RecycleData  bool
+	sequence     int
+	m            *LoadManager
+	config       Config
+	attackers    []Attack
+	next         chan bool
+	// quits stop attackers, one per attacker
+	quits           []chan bool
 	results         chan result
 	prototype       Attack
 	metrics         map[string]*Metrics
 	resultsPipeline func(r result) result
+	// pipelineMu guards resultsPipeline swapped by rampup while results are collected
+	pipelineMu sync.Mutex
+	// collected is closed when all results are collected
+	collected chan struct{}
+	live      *liveStats
+	slo       *sloMonitor
+
+	fullAttackStartedAt  time.Time
+	fullAttackFinishedAt time.Time
+	// sendAttempts and blockedSends count rate tokens of full attack, tokens delayed by more than
+	// token interval waiting for a free attacker are blocked, used to detect generator saturation
+	sendAttempts uint64
+	blockedSends uint64
+
+	// mu guards config reloaded during run, pending changes are applied by runner goroutine
+	mu       sync.Mutex
+	desired  Config
+	pending  *Config
+	changes  []ConfigChange
+	finished bool
+	// retired are attackers stopped when max attackers is reduced, they are torn down with the rest
+	retired []Attack
 }
 
 func NewRunner(name string, lm *LoadManager, a Attack, c Config) *Runner {
@@ -47,6 +72,7 @@ func NewRunner(name string, lm *LoadManager, a Attack, c Config) *Runner {
 	r.name = name
 	r.m = lm
 	r.config = c
+	r.desired = c
 	r.prototype = a
 	r.sequence = c.SequenceNum
 	if c.Verbose {
@@ -61,25 +87,20 @@ func NewRunner(name string, lm *LoadManager, a Attack, c Config) *Runner {
 		}
 		fmt.Println()
 		flag.Usage()
-		os.Exit(0)
+		os.Exit(2)
 	}
 
 	// is the attacker interested in the Run lifecycle?
 	if lifecycler, ok := a.(BeforeRunner); ok {
 		if err := lifecycler.BeforeRun(c); err != nil {
-			log.Fatalln("BeforeRun failed", err)
+			lm.Fatalf("[ %s ] BeforeRun failed: %s", name, err)
 		}
 	}
 
 	// do a test if the flag says so
 	if *oSample > 0 {
-		r.test(*oSample)
-		report := RunReport{}
-		if lifecycler, ok := a.(AfterRunner); ok {
-			if err := lifecycler.AfterRun(&report); err != nil {
-				log.Fatalln("AfterRun failed", err)
-			}
-		}
+		r.init()
+		r.sampleRun(*oSample)
 		os.Exit(0)
 		// unreachable
 		return r
@@ -90,11 +111,13 @@ func NewRunner(name string, lm *LoadManager, a Attack, c Config) *Runner {
 
 func (r *Runner) init() {
 	r.next = make(chan bool)
-	r.quit = make(chan bool)
+	r.quits = nil
 	r.results = make(chan result)
 	r.attackers = []Attack{}
 	r.metrics = make(map[string]*Metrics)
 	r.resultsPipeline = r.addResult
+	r.live = newLiveStats()
+	r.slo = newSLOMonitor(r.name, r.config)
 }
 
 func (r *Runner) spawnAttacker() {
@@ -106,8 +129,11 @@ func (r *Runner) spawnAttacker() {
 		log.Printf("[%s] attacker [%d] setup failed with [%v]\n", r.name, len(r.attackers)+1, err)
 		return
 	}
+	quit := make(chan bool)
 	r.attackers = append(r.attackers, attacker)
-	go attack(attacker, r.next, r.quit, r.results, r.config.timeout())
+	r.quits = append(r.quits, quit)
+	r.live.setAttackers(len(r.attackers))
+	go attack(attacker, r.m.tracer, r.next, quit, r.results, r.config.timeout())
 }
 
 // addResult is called from a dedicated goroutine.
@@ -117,26 +143,53 @@ func (r *Runner) addResult(s result) result {
 		m = new(Metrics)
 		r.metrics[s.doResult.RequestLabel] = m
 	}
-	m.add(s)
+	m.addTagged(s, r.config.TagDimensions)
 	return s
 }
 
 // test uses the Attack to perform {count} calls and report its result
 // it is intended for development of an Attack implementation.
-func (r *Runner) test(count int) {
+func (r *Runner) test(count int) map[string]*Metrics {
 	probe := r.prototype.Clone()
 	if err := probe.Setup(r.m, r.config); err != nil {
 		log.Printf("test attack setup failed [%v]", err)
-		return
+		return r.metrics
 	}
 	defer probe.Teardown()
 	for s := count; s > 0; s-- {
 		now := time.Now()
-		result := probe.Do(context.Background())
-		log.Printf("test attack call [%s] took [%v] with status [%v] and error [%v]\n", result.RequestLabel, time.Now().Sub(now), result.StatusCode, result.Error)
+		dor := probe.Do(context.Background())
+		end := time.Now()
+		log.Printf("test attack call [%s] took [%v] with status [%v] and error [%v]\n", dor.RequestLabel, end.Sub(now), dor.StatusCode, dor.Error)
+		r.addResult(result{doResult: dor, begin: now, end: end, elapsed: end.Sub(now)})
+	}
+	for _, m := range r.metrics {
+		m.updateLatencies()
+	}
+	return r.metrics
+}
+
+// sampleRun makes test calls and passes their report to AfterRun
+func (r *Runner) sampleRun(count int) *RunReport {
+	startedAt := time.Now()
+	metrics := r.test(count)
+	report := &RunReport{
+		StartedAt:     startedAt,
+		FinishedAt:    time.Now(),
+		Configuration: r.config,
+		Metrics:       metrics,
+		Output:        map[string]interface{}{},
 	}
+	if lifecycler, ok := r.prototype.(AfterRunner); ok {
+		if err := lifecycler.AfterRun(report); err != nil {
+			r.m.Fatalf("[ %s ] AfterRun failed: %s", r.name, err)
+		}
+	}
+	return report
 }
 
+// SetupHandleStore opens csv files of handle, store is locked only while files are added,
+// so handles of dag suite can setup their stores while other handles run
 func (r *Runner) SetupHandleStore(m *LoadManager) {
 	csvReadName := r.config.ReadFromCsvName
 	recycleData := r.config.RecycleData
@@ -144,15 +197,19 @@ func (r *Runner) SetupHandleStore(m *LoadManager) {
 		log.Printf("creating read file: %s\n", csvReadName)
 		f, err := os.Open(csvReadName)
 		if err != nil {
-			log.Fatalf("no csv read file found: %s", csvReadName)
+			m.Fatalf("no csv read file found: %s", csvReadName)
 		}
+		m.CsvMu.Lock()
 		m.CsvStore[csvReadName] = NewCSVData(f, recycleData)
+		m.CsvMu.Unlock()
 	}
 	csvWriteName := r.config.WriteToCsvName
 	if csvWriteName != "" {
 		log.Printf("creating write file: %s\n", csvWriteName)
 		csvFile := createIfNotExists(csvWriteName)
+		m.CsvMu.Lock()
 		m.CsvStore[csvWriteName] = NewCSVData(csvFile, false)
+		m.CsvMu.Unlock()
 	}
 }
 
@@ -163,46 +220,137 @@ func (r *Runner) Run(wg *sync.WaitGroup, lm *LoadManager) {
 	}
 	if lifecycler, ok := r.prototype.(BeforeRunner); ok {
 		if err := lifecycler.BeforeRun(r.config); err != nil {
-			log.Fatalln("BeforeRun failed", err)
+			lm.Fatalf("[ %s ] BeforeRun failed: %s", r.name, err)
 		}
 	}
+	startedAt := time.Now()
+	r.applyReload()
+	r.collected = make(chan struct{})
 	go r.collectResults()
+	r.live.setPhase(PhaseRampUp)
 	r.rampUp()
+	r.live.setPhase(PhaseAttack)
 	r.fullAttack()
+	r.live.setPhase(PhaseStopping)
 	r.quitAttackers()
+	// attackers are stopped, no results are sent anymore
+	close(r.results)
+	<-r.collected
 	r.tearDownAttackers()
-	report := RunReport{}
+	r.live.setAttackers(0)
+	r.live.setPhase(PhaseDone)
+	runReport := r.reportMetrics()
+	runReport.ConfigChanges = r.finishReload()
+	runReport.Provenance = lm.Provenance
+	runReport.GuardBreaches = lm.GuardBreachesBetween(startedAt, runReport.FinishedAt)
+	if len(runReport.GuardBreaches) > 0 {
+		runReport.Failed = true
+	}
+	runReport.GeneratorHealth = r.generatorHealth(lm.selfMonitor.SamplesBetween(startedAt, runReport.FinishedAt))
+	runReport.Unreliable = runReport.GeneratorHealth.Unreliable()
+	logGeneratorHealth(r.name, runReport.GeneratorHealth)
+	runReport.Assertions = r.slo.Results(r.fullAttackFinishedAt)
+	for _, a := range runReport.Assertions {
+		log.Printf("[ %s ] SLO %s", r.name, a)
+	}
+	failedAssertions := FailedAssertions(runReport.Assertions)
+	if failedAssertions {
+		runReport.Failed = true
+	}
 	if lifecycler, ok := r.prototype.(AfterRunner); ok {
-		if err := lifecycler.AfterRun(&report); err != nil {
-			log.Fatalln("AfterRun failed", err)
+		if err := lifecycler.AfterRun(runReport); err != nil {
+			log.Printf("[%s] AfterRun failed: %v\n", r.name, err)
+			runReport.RunError = err.Error()
+			runReport.Failed = true
 		}
 	}
+	if runReport.Output == nil {
+		runReport.Output = map[string]interface{}{}
+	}
 	lm.CsvMu.Lock()
 	defer lm.CsvMu.Unlock()
-	lm.Reports[r.name] = r.reportMetrics()
+	if runReport.Failed {
+		log.Printf("[%s] handle run marked as failed\n", r.name)
+		lm.markFailed(failedAssertions)
+	}
+	lm.Reports[r.name] = runReport
 }
 
 func (r *Runner) fullAttack() {
 	// attack can only proceed when at least one attacker is waiting for rps tokens
-	if len(r.attackers) == 0 {
+	if len(r.attackers) == 0 || r.m.IsAborted() {
 		// rampup probably has failed too
 		return
 	}
 	if r.config.Verbose {
 		log.Printf("begin full attack of [%d] remaining seconds\n", r.config.AttackTimeSec-r.config.RampUpTimeSec)
 	}
-	fullAttackStartedAt = time.Now()
+	r.fullAttackStartedAt = time.Now()
+	r.slo.Start()
+	defer func() {
+		r.slo.Stop()
+		r.fullAttackFinishedAt = time.Now()
+	}()
 	limiter := ratelimit.New(r.config.RPS) // per second
+	r.live.setTargetRPS(r.config.RPS)
 	doneDeadline := time.Now().Add(time.Duration(r.config.AttackTimeSec-r.config.RampUpTimeSec) * time.Second)
 	for time.Now().Before(doneDeadline) {
+		if r.m.IsAborted() {
+			log.Printf("[%s] full attack aborted by guard\n", r.name)
+			break
+		}
+		if r.slo.AbortRequested() {
+			log.Printf("[%s] full attack aborted by failed assertion\n", r.name)
+			break
+		}
+		if r.applyReload() {
+			limiter = ratelimit.New(r.config.RPS)
+			r.live.setTargetRPS(r.config.RPS)
+		}
 		limiter.Take()
-		r.next <- true
+		r.sendNext(time.Second / time.Duration(r.config.RPS))
 	}
 	if r.config.Verbose {
 		log.Printf("end full attack")
 	}
 }
 
+// sendNext puts one attacker to work, counting tokens that waited for a free attacker longer than token interval,
+// short waits for attackers busy in Do are normal
+func (r *Runner) sendNext(interval time.Duration) {
+	r.sendAttempts++
+	select {
+	case r.next <- true:
+		return
+	default:
+	}
+	begin := time.Now()
+	r.next <- true
+	if time.Since(begin) > interval {
+		r.blockedSends++
+	}
+}
+
+// generatorHealth checks if load generator was able to keep target rate, it is called after results are collected
+func (r *Runner) generatorHealth(samples []GeneratorSample) *GeneratorHealth {
+	if r.fullAttackStartedAt.IsZero() {
+		h := newGeneratorHealth(samples, 0, 0, 0, 0)
+		h.TargetRate = float64(r.config.RPS)
+		h.Skipped = true
+		log.Printf("%s [%s] full attack did not start, rate checks are skipped\n", generatorHealthLogPrefix, r.name)
+		return h
+	}
+	var achievedRate float64
+	if d := r.fullAttackFinishedAt.Sub(r.fullAttackStartedAt).Seconds(); d > 0 {
+		var requests uint64
+		for _, m := range r.metrics {
+			requests += m.Requests
+		}
+		achievedRate = float64(requests) / d
+	}
+	return newGeneratorHealth(samples, float64(r.config.RPS), achievedRate, r.sendAttempts, r.blockedSends)
+}
+
 func (r *Runner) rampUp() {
 	strategy := r.config.rampupStrategy()
 	if r.config.Verbose {
@@ -215,7 +363,7 @@ func (r *Runner) rampUp() {
 		spawnAsWeNeedStrategy{}.execute(r)
 	}
 	// restore pipeline function incase it was changed by the rampup strategy
-	r.resultsPipeline = r.addResult
+	r.setResultsPipeline(r.addResult)
 	if r.config.Verbose {
 		log.Printf("end rampup ending up with [%d] attackers\n", len(r.attackers))
 	}
@@ -225,8 +373,8 @@ func (r *Runner) quitAttackers() {
 	if r.config.Verbose {
 		log.Printf("stopping attackers [%d]\n", len(r.attackers))
 	}
-	for range r.attackers {
-		r.quit <- true
+	for _, quit := range r.quits {
+		quit <- true
 	}
 }
 
@@ -234,7 +382,7 @@ func (r *Runner) tearDownAttackers() {
 	if r.config.Verbose {
 		log.Printf("tearing down attackers [%d]\n", len(r.attackers))
 	}
-	for i, each := range r.attackers {
+	for i, each := range append(r.attackers, r.retired...) {
 		if err := each.Teardown(); err != nil {
 			log.Printf("failed to teardown attacker [%d]:%v\n", i, err)
 		}
@@ -246,7 +394,7 @@ func (r *Runner) reportMetrics() *RunReport {
 		each.updateLatencies()
 	}
 	return &RunReport{
-		StartedAt:     fullAttackStartedAt,
+		StartedAt:     r.fullAttackStartedAt,
 		FinishedAt:    time.Now(),
 		Configuration: r.config,
 		Metrics:       r.metrics,
@@ -255,8 +403,21 @@ func (r *Runner) reportMetrics() *RunReport {
 	}
 }
 
+// collectResults collects results until results channel is closed
 func (r *Runner) collectResults() {
-	for {
-		r.resultsPipeline(<-r.results)
+	defer close(r.collected)
+	for res := range r.results {
+		r.live.add(res)
+		r.slo.add(res)
+		r.pipelineMu.Lock()
+		r.resultsPipeline(res)
+		r.pipelineMu.Unlock()
 	}
 }
+
+// setResultsPipeline swaps pipeline function, previous one receives no results after it returns
+func (r *Runner) setResultsPipeline(f func(r result) result) {
+	r.pipelineMu.Lock()
+	r.resultsPipeline = f
+	r.pipelineMu.Unlock()
+}
@@ -0,0 +1,57 @@
+package loadgen
+
+import (
+	"testing"
+)
+
+type verifyingAttack struct {
+	attackMock
+	seen *RunReport
+}
+
+func (a *verifyingAttack) Clone() Attack {
+	return a
+}
+
+func (a *verifyingAttack) AfterRun(r *RunReport) error {
+	a.seen = r
+	if r.Metrics[""].Requests > 0 {
+		r.Failed = true
+		r.Output["verified"] = r.Metrics[""].Requests
+	}
+	return nil
+}
+
+func TestAfterRunReceivesReport(t *testing.T) {
+	lm := NewLoadManager()
+	a := new(verifyingAttack)
+	c := Config{RPS: 5, AttackTimeSec: 2, RampUpTimeSec: 1, MaxAttackers: 2, DoTimeoutSec: 1}
+	NewRunner("verify", lm, a, c).Run(nil, lm)
+	if a.seen == nil || a.seen.Configuration.RPS != 5 {
+		t.Fatal("expected populated report in AfterRun")
+	}
+	r := lm.Reports["verify"]
+	if !r.Failed {
+		t.Error("expected report marked as failed by attack")
+	}
+	if _, ok := r.Output["verified"]; !ok {
+		t.Error("expected attack output in stored report")
+	}
+	if !lm.Failed {
+		t.Error("expected failed suite")
+	}
+}
+
+func TestSampleRunReport(t *testing.T) {
+	lm := NewLoadManager()
+	a := new(verifyingAttack)
+	r := &Runner{name: "verify", m: lm, prototype: a, config: Config{RPS: 5, AttackTimeSec: 2, RampUpTimeSec: 1, MaxAttackers: 2, DoTimeoutSec: 1}}
+	r.init()
+	report := r.sampleRun(3)
+	if a.seen != report {
+		t.Fatal("expected sample report in AfterRun")
+	}
+	if got, want := report.Output["verified"], uint64(3); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,212 @@
+package loadgen
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultGeneratorSampleIntervalSec   = 1
+	defaultGeneratorCPUThresholdPercent = 90.0
+	defaultRateTolerance                = 0.95
+	defaultBlockedSendsThresholdPercent = 5.0
+	generatorHealthLogPrefix            = "[ generator-health ]"
+)
+
+// GeneratorSample is a snapshot of load generator process resources
+type GeneratorSample struct {
+	At time.Time `json:"at"`
+	// CPUPercent is process CPU usage as percent of all available CPUs
+	CPUPercent float64       `json:"cpu_percent"`
+	HeapAlloc  uint64        `json:"heap_alloc"`
+	MaxGCPause time.Duration `json:"max_gc_pause"`
+	Goroutines int           `json:"goroutines"`
+}
+
+// GeneratorHealth summarizes load generator resources and saturation during handle run
+type GeneratorHealth struct {
+	MaxCPUPercent float64       `json:"max_cpu_percent"`
+	MaxHeapAlloc  uint64        `json:"max_heap_alloc"`
+	MaxGCPause    time.Duration `json:"max_gc_pause"`
+	MaxGoroutines int           `json:"max_goroutines"`
+	// TargetRate is configured RPS of the handle
+	TargetRate float64 `json:"target_rate"`
+	// AchievedRate is the rate of requests sent during full attack
+	AchievedRate float64 `json:"achieved_rate"`
+	// SendAttempts is the number of rate tokens sent to attackers
+	SendAttempts uint64 `json:"send_attempts"`
+	// BlockedSends is the number of rate tokens that waited for a free attacker longer than token interval
+	BlockedSends uint64 `json:"blocked_sends"`
+	// Skipped is set when full attack never started, rate and saturation are not checked
+	Skipped  bool     `json:"skipped,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// SelfMonitor samples load generator process resources during the run
+type SelfMonitor struct {
+	interval time.Duration
+	mu       sync.Mutex
+	samples  []GeneratorSample
+	quit     chan struct{}
+	wg       sync.WaitGroup
+
+	lastCPU  time.Duration
+	lastWall time.Time
+	lastGC   uint32
+}
+
+// NewSelfMonitor creates sampler with checks.generator_sample_interval_sec interval
+func NewSelfMonitor() *SelfMonitor {
+	interval := viper.GetInt("checks.generator_sample_interval_sec")
+	if interval <= 0 {
+		interval = defaultGeneratorSampleIntervalSec
+	}
+	return &SelfMonitor{
+		interval: time.Duration(interval) * time.Second,
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start starts sampling in background
+func (s *SelfMonitor) Start() {
+	s.lastCPU, _ = processCPUTime()
+	s.lastWall = time.Now()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	s.lastGC = ms.NumGC
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.sample()
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops sampling
+func (s *SelfMonitor) Stop() {
+	if s == nil {
+		return
+	}
+	close(s.quit)
+	s.wg.Wait()
+}
+
+func (s *SelfMonitor) sample() {
+	now := time.Now()
+	smp := GeneratorSample{
+		At:         now,
+		Goroutines: runtime.NumGoroutine(),
+	}
+	if cpu, ok := processCPUTime(); ok {
+		wall := now.Sub(s.lastWall)
+		if wall > 0 {
+			smp.CPUPercent = float64(cpu-s.lastCPU) / float64(wall) / float64(runtime.NumCPU()) * 100
+		}
+		s.lastCPU = cpu
+	}
+	s.lastWall = now
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	smp.HeapAlloc = ms.HeapAlloc
+	// PauseNs is a circular buffer of recent pauses
+	gcs := ms.NumGC - s.lastGC
+	if gcs > uint32(len(ms.PauseNs)) {
+		gcs = uint32(len(ms.PauseNs))
+	}
+	for i := uint32(0); i < gcs; i++ {
+		p := time.Duration(ms.PauseNs[(ms.NumGC-i+255)%256])
+		if p > smp.MaxGCPause {
+			smp.MaxGCPause = p
+		}
+	}
+	s.lastGC = ms.NumGC
+	s.mu.Lock()
+	s.samples = append(s.samples, smp)
+	s.mu.Unlock()
+}
+
+// SamplesBetween returns samples taken in time range
+func (s *SelfMonitor) SamplesBetween(from time.Time, to time.Time) []GeneratorSample {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []GeneratorSample
+	for _, smp := range s.samples {
+		if !smp.At.Before(from) && !smp.At.After(to) {
+			res = append(res, smp)
+		}
+	}
+	return res
+}
+
+// newGeneratorHealth summarizes samples and checks if generator was the bottleneck
+func newGeneratorHealth(samples []GeneratorSample, targetRate float64, achievedRate float64, sendAttempts uint64, blockedSends uint64) *GeneratorHealth {
+	h := &GeneratorHealth{
+		TargetRate:   targetRate,
+		AchievedRate: achievedRate,
+		SendAttempts: sendAttempts,
+		BlockedSends: blockedSends,
+	}
+	for _, smp := range samples {
+		if smp.CPUPercent > h.MaxCPUPercent {
+			h.MaxCPUPercent = smp.CPUPercent
+		}
+		if smp.HeapAlloc > h.MaxHeapAlloc {
+			h.MaxHeapAlloc = smp.HeapAlloc
+		}
+		if smp.MaxGCPause > h.MaxGCPause {
+			h.MaxGCPause = smp.MaxGCPause
+		}
+		if smp.Goroutines > h.MaxGoroutines {
+			h.MaxGoroutines = smp.Goroutines
+		}
+	}
+	cpuThreshold := viperFloatOrDefault("checks.generator_cpu_threshold_percent", defaultGeneratorCPUThresholdPercent)
+	if h.MaxCPUPercent >= cpuThreshold {
+		h.Warnings = append(h.Warnings, fmt.Sprintf("generator cpu usage %.1f%% reached threshold %.1f%%", h.MaxCPUPercent, cpuThreshold))
+	}
+	rateTolerance := viperFloatOrDefault("checks.rate_tolerance", defaultRateTolerance)
+	if targetRate > 0 && achievedRate < targetRate*rateTolerance {
+		h.Warnings = append(h.Warnings, fmt.Sprintf("achieved rate %.2f is below target rate %.2f", achievedRate, targetRate))
+	}
+	blockedThreshold := viperFloatOrDefault("checks.blocked_sends_threshold_percent", defaultBlockedSendsThresholdPercent)
+	if sendAttempts > 0 {
+		if blocked := float64(blockedSends) / float64(sendAttempts) * 100; blocked > blockedThreshold {
+			h.Warnings = append(h.Warnings, fmt.Sprintf("%.1f%% of requests waited for a free attacker longer than token interval, consider raising max_attackers", blocked))
+		}
+	}
+	return h
+}
+
+// Unreliable returns true when load generator was the bottleneck
+func (h *GeneratorHealth) Unreliable() bool {
+	return h != nil && len(h.Warnings) > 0
+}
+
+func viperFloatOrDefault(key string, def float64) float64 {
+	if !viper.IsSet(key) {
+		return def
+	}
+	return viper.GetFloat64(key)
+}
+
+func logGeneratorHealth(handleName string, h *GeneratorHealth) {
+	for _, w := range h.Warnings {
+		log.Printf("%s [%s] %s, results may be unreliable\n", generatorHealthLogPrefix, handleName, w)
+	}
+}
@@ -0,0 +1,69 @@
+package loadgen
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGeneratorHealthRateBelowTarget(t *testing.T) {
+	h := newGeneratorHealth(nil, 100, 50, 100, 0)
+	if !h.Unreliable() {
+		t.Error("expected unreliable generator")
+	}
+}
+
+func TestGeneratorHealthBlockedSends(t *testing.T) {
+	h := newGeneratorHealth(nil, 100, 100, 100, 50)
+	if !h.Unreliable() {
+		t.Error("expected unreliable generator")
+	}
+}
+
+func TestGeneratorHealthOk(t *testing.T) {
+	samples := []GeneratorSample{
+		{CPUPercent: 10, HeapAlloc: 1, Goroutines: 5},
+		{CPUPercent: 20, HeapAlloc: 3, Goroutines: 4},
+	}
+	h := newGeneratorHealth(samples, 100, 99, 100, 1)
+	if h.Unreliable() {
+		t.Errorf("expected reliable generator, got warnings: %v", h.Warnings)
+	}
+	if got, want := h.MaxCPUPercent, 20.0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := h.MaxGoroutines, 5; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestSendNextCountsBlocked(t *testing.T) {
+	r := &Runner{next: make(chan bool)}
+	receive := func(after time.Duration) {
+		go func() {
+			time.Sleep(after)
+			<-r.next
+		}()
+	}
+	// attacker busy shorter than token interval is not a blocked send
+	receive(5 * time.Millisecond)
+	r.sendNext(100 * time.Millisecond)
+	if got, want := r.blockedSends, uint64(0); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	receive(50 * time.Millisecond)
+	r.sendNext(10 * time.Millisecond)
+	if got, want := r.blockedSends, uint64(1); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := r.sendAttempts, uint64(2); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestGeneratorHealthSkippedFullAttack(t *testing.T) {
+	r := &Runner{name: "transfer", config: Config{RPS: 100}}
+	h := r.generatorHealth(nil)
+	if !h.Skipped || h.Unreliable() {
+		t.Errorf("expected skipped reliable health, got %+v", h)
+	}
+}
@@ -0,0 +1,480 @@
+package loadgen
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAssertionWindow = 10 * time.Second
+
+	AssertionRate = "rate"
+	AssertionMean = "mean"
+)
+
+var assertionRe = regexp.MustCompile(`^\s*([a-z0-9_]+)\s*(<=|>=|<|>)\s*(.+?)\s*$`)
+
+// Assertion is a parsed SLO expression, e.g. p99 < 300ms, error_rate < 0.5% or rate >= 0.95*target
+type Assertion struct {
+	Expr   string
+	Metric string
+	Op     string
+	// Value is a limit in metric units: latency in ms, error and success rate as fraction, rate in rps
+	Value float64
+	// TargetFactor is set when limit is relative to target rps of the handle
+	TargetFactor float64
+}
+
+// ParseAssertion parses expression of metric, comparison operator and limit, metrics are
+// p50, p95, p99, max, mean, error_rate, success_rate and rate, latency limits are durations
+// or milliseconds, rates can be set in percents, rate can be relative to target rps
+func ParseAssertion(expr string) (Assertion, error) {
+	m := assertionRe.FindStringSubmatch(expr)
+	if m == nil {
+		return Assertion{}, fmt.Errorf("assertion [%s] must be <metric> <op> <value>, e.g. p99 < 300ms", expr)
+	}
+	a := Assertion{Expr: strings.TrimSpace(expr), Metric: m[1], Op: m[2]}
+	value := strings.Replace(m[3], " ", "", -1)
+	var err error
+	switch a.Metric {
+	case ThresholdP50, ThresholdP95, ThresholdP99, ThresholdMax, AssertionMean:
+		if d, derr := time.ParseDuration(value); derr == nil {
+			a.Value = durationMs(d)
+		} else {
+			a.Value, err = strconv.ParseFloat(value, 64)
+		}
+	case ThresholdErrorRate, ThresholdSuccessRate:
+		if strings.HasSuffix(value, "%") {
+			a.Value, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			a.Value /= 100
+		} else {
+			a.Value, err = strconv.ParseFloat(value, 64)
+		}
+	case AssertionRate:
+		switch {
+		case value == "target":
+			a.TargetFactor = 1
+		case strings.HasSuffix(value, "*target"):
+			a.TargetFactor, err = strconv.ParseFloat(strings.TrimSuffix(value, "*target"), 64)
+		default:
+			a.Value, err = strconv.ParseFloat(value, 64)
+		}
+	default:
+		return Assertion{}, fmt.Errorf("assertion [%s]: unknown metric [%s], possible values are {p50,p95,p99,max,mean,error_rate,success_rate,rate}", expr, a.Metric)
+	}
+	if err != nil {
+		return Assertion{}, fmt.Errorf("assertion [%s]: bad value [%s]", expr, m[3])
+	}
+	return a, nil
+}
+
+// limit returns assertion limit for handle target rps
+func (a Assertion) limit(targetRPS int) float64 {
+	if a.TargetFactor > 0 {
+		return a.TargetFactor * float64(targetRPS)
+	}
+	return a.Value
+}
+
+func (a Assertion) holds(value, limit float64) bool {
+	switch a.Op {
+	case "<":
+		return value < limit
+	case "<=":
+		return value <= limit
+	case ">":
+		return value > limit
+	}
+	return value >= limit
+}
+
+// AssertionResult is a result of SLO assertion evaluated over sliding windows and the whole full attack
+type AssertionResult struct {
+	Expr  string `json:"expr"`
+	Label string `json:"label,omitempty"`
+	// Passed is set when assertion holds for the whole full attack and in every window
+	Passed bool    `json:"passed"`
+	Value  float64 `json:"value"`
+	Limit  float64 `json:"limit"`
+	// Windows is the number of evaluated sliding windows, FailedWindows of them failed
+	Windows       int        `json:"windows"`
+	FailedWindows int        `json:"failedWindows"`
+	WorstValue    float64    `json:"worstValue,omitempty"`
+	FirstFailedAt *time.Time `json:"firstFailedAt,omitempty"`
+	// AbortedRun is set when the failure stopped the handle because of abort_on_fail
+	AbortedRun bool `json:"abortedRun,omitempty"`
+}
+
+func (r AssertionResult) String() string {
+	status := "passed"
+	if !r.Passed {
+		status = "failed"
+	}
+	s := fmt.Sprintf("%s: %s, value %.4g, limit %.4g", r.Expr, status, r.Value, r.Limit)
+	if r.Label != "" {
+		s = r.Label + " " + s
+	}
+	if r.FailedWindows > 0 {
+		s += fmt.Sprintf(", failed in %d of %d windows, worst %.4g", r.FailedWindows, r.Windows, r.WorstValue)
+	}
+	if r.AbortedRun {
+		s += ", handle aborted"
+	}
+	return s
+}
+
+// sloBucket holds results of one second or of a longer period
+type sloBucket struct {
+	requests  uint64
+	errors    uint64
+	latencies LatencyHistogram
+}
+
+func (b *sloBucket) add(failed bool, elapsed time.Duration) {
+	b.requests++
+	if failed {
+		b.errors++
+	}
+	b.latencies.add(elapsed)
+}
+
+func (b *sloBucket) merge(o *sloBucket) {
+	b.requests += o.requests
+	b.errors += o.errors
+	for i, c := range o.latencies.counts {
+		if b.latencies.counts == nil {
+			b.latencies.counts = map[int]uint64{}
+		}
+		b.latencies.counts[i] += c
+	}
+}
+
+// sloSeries holds results of a label: per second buckets of the longest window and totals of full attack
+type sloSeries struct {
+	seconds map[int64]*sloBucket
+	total   sloBucket
+}
+
+type sloAssertion struct {
+	Assertion
+	label       string
+	window      time.Duration
+	abortOnFail bool
+	result      AssertionResult
+}
+
+// sloMonitor evaluates handle SLO assertions over sliding windows during full attack
+type sloMonitor struct {
+	mu         sync.Mutex
+	name       string
+	targetRPS  int
+	assertions []*sloAssertion
+	// series are per label results, empty label holds results of the whole handle
+	series map[string]*sloSeries
+	// maxWindow is the longest assertion window, older seconds are dropped
+	maxWindow time.Duration
+	// delay is the Do() timeout, windows are evaluated when requests started in them are completed
+	delay     time.Duration
+	startedAt time.Time
+	abort     bool
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// newSLOMonitor creates monitor for thresholds with expressions, nil when there are none
+func newSLOMonitor(name string, c Config) *sloMonitor {
+	var assertions []*sloAssertion
+	for _, t := range c.Thresholds {
+		if t.Expr == "" {
+			continue
+		}
+		a, err := ParseAssertion(t.Expr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		window := time.Duration(t.WindowSec) * time.Second
+		if window <= 0 {
+			window = defaultAssertionWindow
+		}
+		assertions = append(assertions, &sloAssertion{
+			Assertion:   a,
+			label:       t.Label,
+			window:      window,
+			abortOnFail: t.AbortOnFail,
+			result:      AssertionResult{Expr: a.Expr, Label: t.Label, Passed: true},
+		})
+	}
+	if len(assertions) == 0 {
+		return nil
+	}
+	return &sloMonitor{
+		name:       name,
+		targetRPS:  c.RPS,
+		assertions: assertions,
+		series:     map[string]*sloSeries{},
+		maxWindow:  maxAssertionWindow(assertions),
+		delay:      c.timeout(),
+	}
+}
+
+func maxAssertionWindow(assertions []*sloAssertion) time.Duration {
+	var max time.Duration
+	for _, a := range assertions {
+		if a.window > max {
+			max = a.window
+		}
+	}
+	return max
+}
+
+// reconfigure updates target rate and assertions of reloaded config, results of unchanged assertions are kept
+func (s *sloMonitor) reconfigure(c Config) {
+	if s == nil {
+		return
+	}
+	var assertions []*sloAssertion
+	if fresh := newSLOMonitor(s.name, c); fresh != nil {
+		assertions = fresh.assertions
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targetRPS = c.RPS
+	s.delay = c.timeout()
+	for _, a := range assertions {
+		for _, old := range s.assertions {
+			if old.Expr == a.Expr && old.label == a.label && old.window == a.window {
+				a.result = old.result
+				break
+			}
+		}
+	}
+	s.assertions = assertions
+	s.maxWindow = maxAssertionWindow(assertions)
+}
+
+// add records result of full attack, results before Start are ignored
+func (s *sloMonitor) add(r result) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.startedAt.IsZero() || r.begin.Before(s.startedAt) {
+		return
+	}
+	failed := r.doResult.Error != nil || r.doResult.StatusCode >= 400
+	sec := r.begin.Unix()
+	labels := []string{""}
+	if r.doResult.RequestLabel != "" {
+		labels = append(labels, r.doResult.RequestLabel)
+	}
+	for _, label := range labels {
+		series, ok := s.series[label]
+		if !ok {
+			series = &sloSeries{seconds: map[int64]*sloBucket{}}
+			s.series[label] = series
+		}
+		b, ok := series.seconds[sec]
+		if !ok {
+			b = &sloBucket{}
+			series.seconds[sec] = b
+		}
+		b.add(failed, r.elapsed)
+		series.total.add(failed, r.elapsed)
+	}
+}
+
+// Start starts sliding windows evaluation every second
+func (s *sloMonitor) Start() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.startedAt = time.Now()
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	s.mu.Unlock()
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				s.evaluateWindows(now)
+			}
+		}
+	}()
+}
+
+// Stop stops windows evaluation
+func (s *sloMonitor) Stop() {
+	if s == nil || s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+// AbortRequested returns true when assertion with abort_on_fail failed
+func (s *sloMonitor) AbortRequested() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.abort
+}
+
+// windowValue computes assertion metric over results started in seconds [from, to) of the rolling window,
+// rate is computed for window duration
+func (s *sloMonitor) windowValue(a *sloAssertion, from, to int64, duration time.Duration) (float64, bool) {
+	var window sloBucket
+	if series, ok := s.series[a.label]; ok {
+		for sec := from; sec < to; sec++ {
+			if b, ok := series.seconds[sec]; ok {
+				window.merge(b)
+			}
+		}
+	}
+	return a.value(&window, duration)
+}
+
+// totalValue computes assertion metric over all results of full attack
+func (s *sloMonitor) totalValue(a *sloAssertion, duration time.Duration) (float64, bool) {
+	var total sloBucket
+	if series, ok := s.series[a.label]; ok {
+		total.merge(&series.total)
+	}
+	return a.value(&total, duration)
+}
+
+// prune drops seconds which are out of the longest window
+func (s *sloMonitor) prune(to int64) {
+	oldest := to - int64(s.maxWindow/time.Second)
+	for _, series := range s.series {
+		for sec := range series.seconds {
+			if sec < oldest {
+				delete(series.seconds, sec)
+			}
+		}
+	}
+}
+
+// value computes assertion metric of results in bucket, rate is computed for duration
+func (a *sloAssertion) value(b *sloBucket, duration time.Duration) (float64, bool) {
+	if b.requests == 0 {
+		return 0, a.Metric == AssertionRate
+	}
+	b.latencies.update()
+	switch a.Metric {
+	case ThresholdErrorRate:
+		return float64(b.errors) / float64(b.requests), true
+	case ThresholdSuccessRate:
+		return float64(b.requests-b.errors) / float64(b.requests), true
+	case AssertionRate:
+		return float64(b.requests) / duration.Seconds(), true
+	case AssertionMean:
+		var sum float64
+		for _, hb := range b.latencies.Buckets {
+			sum += float64(hb.UpperBound) * float64(hb.Count)
+		}
+		return durationMs(time.Duration(sum / float64(b.requests))), true
+	}
+	return durationMs(b.latencies.Quantile(thresholdQuantiles[a.Metric])), true
+}
+
+func (s *sloMonitor) evaluateWindows(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// only complete seconds are evaluated, results arrive when requests end,
+	// so window is delayed by Do() timeout until requests started in it are done
+	to := time.Unix(now.Unix(), 0).Add(-s.delay)
+	defer s.prune(to.Unix())
+	for _, a := range s.assertions {
+		from := to.Add(-a.window)
+		if from.Before(s.startedAt) {
+			continue
+		}
+		value, ok := s.windowValue(a, from.Unix(), to.Unix(), a.window)
+		if !ok {
+			continue
+		}
+		limit := a.limit(s.targetRPS)
+		a.result.Windows++
+		if a.holds(value, limit) {
+			continue
+		}
+		if a.result.FailedWindows == 0 || a.worse(value, a.result.WorstValue) {
+			a.result.WorstValue = value
+		}
+		a.result.FailedWindows++
+		a.result.Passed = false
+		if a.result.FirstFailedAt == nil {
+			at := to
+			a.result.FirstFailedAt = &at
+			log.Printf("[ %s ] SLO assertion %s failed in window %s - %s: %.4g", s.name, a.Expr, from.Format("15:04:05"), to.Format("15:04:05"), value)
+		}
+		if a.abortOnFail && !s.abort {
+			log.Printf("[ %s ] aborting handle on failed assertion %s", s.name, a.Expr)
+			a.result.AbortedRun = true
+			s.abort = true
+		}
+	}
+}
+
+// worse reports if value violates assertion more than other
+func (a *sloAssertion) worse(value, other float64) bool {
+	if a.Op == "<" || a.Op == "<=" {
+		return value > other
+	}
+	return value < other
+}
+
+// Results evaluates assertions over the whole full attack finished at finishedAt
+func (s *sloMonitor) Results(finishedAt time.Time) []AssertionResult {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]AssertionResult, 0, len(s.assertions))
+	for _, a := range s.assertions {
+		r := a.result
+		r.Limit = a.limit(s.targetRPS)
+		if s.startedAt.IsZero() {
+			// full attack never started
+			res = append(res, r)
+			continue
+		}
+		duration := finishedAt.Sub(s.startedAt)
+		if duration < time.Second {
+			duration = time.Second
+		}
+		if value, ok := s.totalValue(a, duration); ok {
+			r.Value = value
+			if !a.holds(value, r.Limit) {
+				r.Passed = false
+			}
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// FailedAssertions returns true if any assertion failed
+func FailedAssertions(results []AssertionResult) bool {
+	for _, r := range results {
+		if !r.Passed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package loadgen
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseAssertion(t *testing.T) {
+	for _, each := range []struct {
+		expr   string
+		metric string
+		value  float64
+		factor float64
+	}{
+		{"p99 < 300ms", ThresholdP99, 300, 0},
+		{"p50<=0.5s", ThresholdP50, 500, 0},
+		{"max < 42", ThresholdMax, 42, 0},
+		{"error_rate < 0.5%", ThresholdErrorRate, 0.005, 0},
+		{"success_rate >= 0.99", ThresholdSuccessRate, 0.99, 0},
+		{"rate >= 0.95*target", AssertionRate, 0, 0.95},
+		{"rate > 100", AssertionRate, 100, 0},
+	} {
+		a, err := ParseAssertion(each.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := a.Metric, each.metric; got != want {
+			t.Errorf("got %v want %v", got, want)
+		}
+		if got, want := a.Value, each.value; got != want {
+			t.Errorf("%s: got %v want %v", each.expr, got, want)
+		}
+		if got, want := a.TargetFactor, each.factor; got != want {
+			t.Errorf("got %v want %v", got, want)
+		}
+	}
+	for _, bad := range []string{"p42 < 1ms", "p99 300ms", "rate >= fast"} {
+		if _, err := ParseAssertion(bad); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestSLOMonitorWindows(t *testing.T) {
+	s := newSLOMonitor("transfer", Config{RPS: 10, Thresholds: []Threshold{
+		{Expr: "error_rate < 10%", WindowSec: 2, AbortOnFail: true},
+		{Expr: "p99 < 300ms", Label: "fast"},
+		{Metric: ThresholdP50, Relative: 1.2},
+	}})
+	if got, want := len(s.assertions), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	start := time.Unix(1000, 0)
+	s.startedAt = start
+	for i := 0; i < 40; i++ {
+		begin := start.Add(time.Duration(i) * 100 * time.Millisecond)
+		res := result{begin: begin, end: begin.Add(10 * time.Millisecond), elapsed: 10 * time.Millisecond, doResult: DoResult{RequestLabel: "fast"}}
+		// third second fails completely
+		if i >= 20 && i < 30 {
+			res.doResult.Error = errors.New("boom")
+		}
+		s.add(res)
+	}
+	s.evaluateWindows(start.Add(2 * time.Second))
+	if s.AbortRequested() {
+		t.Fatal("unexpected abort")
+	}
+	s.evaluateWindows(start.Add(3 * time.Second))
+	if !s.AbortRequested() {
+		t.Fatal("expected abort on failed window")
+	}
+	results := s.Results(start.Add(4 * time.Second))
+	if results[0].Passed {
+		t.Errorf("expected failed assertion: %s", results[0])
+	}
+	if got, want := results[0].WorstValue, 0.5; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := results[0].Value, 0.25; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if !results[1].Passed {
+		t.Errorf("expected passed assertion: %s", results[1])
+	}
+	if !FailedAssertions(results) {
+		t.Error("expected failed assertions")
+	}
+	// seconds out of the longest window are dropped, totals are kept
+	s.evaluateWindows(start.Add(20 * time.Second))
+	if got, want := len(s.series[""].seconds), 0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := s.Results(start.Add(4 * time.Second))[0].Value, 0.25; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestSLOMonitorWaitsForSlowResults(t *testing.T) {
+	s := newSLOMonitor("transfer", Config{RPS: 10, DoTimeoutSec: 2, Thresholds: []Threshold{
+		{Expr: "p99 < 300ms", WindowSec: 1},
+	}})
+	start := time.Unix(1000, 0)
+	s.startedAt = start
+	fast := func(begin time.Time) result {
+		return result{begin: begin, end: begin.Add(10 * time.Millisecond), elapsed: 10 * time.Millisecond}
+	}
+	for i := 0; i < 9; i++ {
+		s.add(fast(start.Add(time.Duration(i) * 100 * time.Millisecond)))
+	}
+	// first second is complete, but slow request started in it is still in flight
+	s.evaluateWindows(start.Add(time.Second))
+	s.evaluateWindows(start.Add(2 * time.Second))
+	if got, want := s.assertions[0].result.Windows, 0; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	begin := start.Add(900 * time.Millisecond)
+	s.add(result{begin: begin, end: begin.Add(1500 * time.Millisecond), elapsed: 1500 * time.Millisecond})
+	s.evaluateWindows(start.Add(3 * time.Second))
+	r := s.assertions[0].result
+	if got, want := r.Windows, 1; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if r.Passed {
+		t.Errorf("expected slow result in window: %s", r)
+	}
+}
+
+func TestDecodeAssertionThresholds(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(strings.NewReader(`
+handles:
+  - name: transfer
+    thresholds:
+      - p99 < 300ms
+      - expr: rate >= 0.95*target
+        abort_on_fail: true
+      - metric: p50
+        relative: 1.2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg SuiteConfig
+	if err := v.Unmarshal(&cfg, viper.DecodeHook(configDecodeHook)); err != nil {
+		t.Fatal(err)
+	}
+	ts := cfg.Handles[0].Thresholds
+	if got, want := len(ts), 3; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := ts[0].Expr, "p99 < 300ms"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if !ts[1].AbortOnFail {
+		t.Error("expected abort on fail")
+	}
+	if got, want := ts[2].Metric, ThresholdP50; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if msg := ValidateThresholds(ts); len(msg) > 0 {
+		t.Errorf("unexpected validation errors %v", msg)
+	}
+}
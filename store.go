@@ -0,0 +1,180 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	ReportStoreFS     = "fs"
+	ReportStoreSQLite = "sqlite"
+	ReportStoreS3     = "s3"
+)
+
+// ReportStore keeps handle reports and baselines as named json documents, names are slash separated,
+// e.g. transfer-1577836800.json or baselines/prod-min/stage.json
+type ReportStore interface {
+	// Put creates or replaces document
+	Put(name string, data []byte) error
+	// Get reads document, error satisfies os.IsNotExist when there is no such document
+	Get(name string) ([]byte, error)
+	// List returns sorted names of documents starting with prefix
+	List(prefix string) ([]string, error)
+}
+
+// ReportStoreFromConfig creates report store from reports.store config, default is filesystem store in reportDir
+//
+//	reports:
+//	  store: s3
+//	  s3:
+//	    endpoint: http://minio:9000
+//	    bucket: load-reports
+//	  sqlite:
+//	    driver: sqlite3 # must be registered by load test binary, e.g. import _ "github.com/mattn/go-sqlite3"
+//	    dsn: load/reports.db
+func ReportStoreFromConfig(reportDir string) ReportStore {
+	s, err := NewReportStoreFromConfig(reportDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return s
+}
+
+// NewReportStoreFromConfig creates report store from reports.store config, returns error when store can not be opened
+func NewReportStoreFromConfig(reportDir string) (ReportStore, error) {
+	switch kind := viper.GetString("reports.store"); kind {
+	case "", ReportStoreFS:
+		return NewFileReportStore(reportDir), nil
+	case ReportStoreSQLite:
+		driver := viper.GetString("reports.sqlite.driver")
+		if driver == "" {
+			driver = DefaultSQLDriver
+		}
+		s, err := NewSQLReportStore(driver, viper.GetString("reports.sqlite.dsn"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sqlite report store: %s", err)
+		}
+		return s, nil
+	case ReportStoreS3:
+		accessKey := viper.GetString("reports.s3.access_key")
+		if accessKey == "" {
+			accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		}
+		secretKey := viper.GetString("reports.s3.secret_key")
+		if secretKey == "" {
+			secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		return NewS3ReportStore(S3Config{
+			Endpoint:  viper.GetString("reports.s3.endpoint"),
+			Region:    viper.GetString("reports.s3.region"),
+			Bucket:    viper.GetString("reports.s3.bucket"),
+			Prefix:    viper.GetString("reports.s3.prefix"),
+			AccessKey: accessKey,
+			SecretKey: secretKey,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown report store [%s], possible values are {fs,sqlite,s3}", kind)
+	}
+}
+
+// FileReportStore keeps documents as files in dir
+type FileReportStore struct {
+	Dir string
+}
+
+// NewFileReportStore creates filesystem store
+func NewFileReportStore(dir string) *FileReportStore {
+	return &FileReportStore{Dir: dir}
+}
+
+func (s *FileReportStore) Put(name string, data []byte) error {
+	p := filepath.Join(s.Dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, data, 0777)
+}
+
+func (s *FileReportStore) Get(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(s.Dir, filepath.FromSlash(name)))
+}
+
+func (s *FileReportStore) List(prefix string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(s.Dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == s.Dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Dir, p)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// PutRunReport stores handle report of suite run, secrets of configuration are redacted
+func PutRunReport(s ReportStore, handleName string, ts int64, r *RunReport) error {
+	stored := *r
+	stored.Configuration = redactedConfig(r.Configuration)
+	data, err := json.MarshalIndent(&stored, "", "    ")
+	if err != nil {
+		return err
+	}
+	return s.Put(ReportFileName(handleName, ts), data)
+}
+
+// GetRunReport reads handle report of suite run
+func GetRunReport(s ReportStore, handleName string, ts int64) (*RunReport, error) {
+	name := ReportFileName(handleName, ts)
+	data, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	var r RunReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal report %s: %s", name, err)
+	}
+	return &r, nil
+}
+
+// storedReportsByTs returns handles having reports in store grouped by suite run timestamp
+func storedReportsByTs(s ReportStore) (map[int64][]string, error) {
+	names, err := s.List("")
+	if err != nil {
+		return nil, err
+	}
+	byTs := map[int64][]string{}
+	for _, name := range names {
+		if path.Dir(name) != "." {
+			continue
+		}
+		if h, ts, ok := HandleFromReportPath(name); ok {
+			byTs[ts] = append(byTs[ts], h)
+		}
+	}
+	return byTs, nil
+}
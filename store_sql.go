@@ -0,0 +1,80 @@
+package loadgen
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// DefaultSQLDriver is a driver of SQLite report store when reports.sqlite.driver is not set,
+// loadgen does not register it, load test binary must import a driver, e.g. import _ "github.com/mattn/go-sqlite3"
+const DefaultSQLDriver = "sqlite3"
+
+// sqlDriverProblem returns a problem when database driver is not registered by load test binary
+func sqlDriverProblem(driver string) string {
+	for _, d := range sql.Drivers() {
+		if d == driver {
+			return ""
+		}
+	}
+	return fmt.Sprintf("sql driver [%s] is not registered, load test binary must import it, e.g. import _ \"github.com/mattn/go-sqlite3\"", driver)
+}
+
+// SQLReportStore keeps documents in a table of SQLite database, database driver must be registered
+// by load test binary, e.g. import _ "github.com/mattn/go-sqlite3"
+type SQLReportStore struct {
+	db *sql.DB
+}
+
+// NewSQLReportStore opens database and creates documents table
+func NewSQLReportStore(driver, dsn string) (*SQLReportStore, error) {
+	if p := sqlDriverProblem(driver); p != "" {
+		return nil, errors.New(p)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS loadgen_reports (name TEXT PRIMARY KEY, data BLOB NOT NULL)`); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLReportStore{db: db}, nil
+}
+
+func (s *SQLReportStore) Put(name string, data []byte) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO loadgen_reports (name, data) VALUES (?, ?)`, name, data)
+	return err
+}
+
+func (s *SQLReportStore) Get(name string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM loadgen_reports WHERE name = ?`, name).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, os.ErrNotExist
+	}
+	return data, err
+}
+
+func (s *SQLReportStore) List(prefix string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT name FROM loadgen_reports WHERE substr(name, 1, ?) = ? ORDER BY name`, len(prefix), prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// Close closes database
+func (s *SQLReportStore) Close() error {
+	return s.db.Close()
+}
@@ -1,7 +1,12 @@
 package loadgen
 
 import (
+	"log"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
 )
 
 const (
@@ -12,21 +17,65 @@ type attackerFactory func(string) Attack
 
 // CIRun default run mode for suite, with degradation checks
 func CIRun(factory attackerFactory) {
+	if RunCommand(os.Args[1:]) {
+		return
+	}
 	lm := SuiteFromHandles(factory)
 	lm.RunSuite()
 	lm.CheckDegradation()
 	lm.StoreHandleReports()
-	if lm.Degradation || lm.Failed {
+	lm.StoreHTMLReport()
+	lm.StoreJUnitReport()
+	lm.StoreSuiteReport()
+	lm.Notify()
+	if lm.Degradation || lm.Failed || lm.Aborted || lm.AssertionsFailed {
 		os.Exit(1)
 	}
 }
 
+// SuiteName returns suite name from config file name, e.g. prod-min for load/run-configs/prod-min.yaml
+func SuiteName() string {
+	cfgFile := viper.ConfigFileUsed()
+	if cfgFile == "" {
+		return "loadgen"
+	}
+	base := filepath.Base(cfgFile)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// HandleConfigs returns handle configs with suite defaults of tag dimensions and thresholds
+func (s *SuiteConfig) HandleConfigs() []Config {
+	res := make([]Config, 0, len(s.Handles))
+	for _, c := range s.Handles {
+		if c.TagDimensions == nil {
+			c.TagDimensions = s.TagDimensions
+		}
+		if c.Thresholds == nil {
+			c.Thresholds = s.Thresholds
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
 // FromHandles starts generators for all handles from config
 func SuiteFromHandles(factory attackerFactory) *LoadManager {
 	suiteCfg := LoadAttackProfileCfg()
 
 	lm := NewLoadManager()
-	for _, handleVal := range suiteCfg.Handles {
+	lm.tracer = InitTracing()
+	lm.BaselineKey = CurrentBaselineKey()
+	if dir := viper.GetString("reports.dir"); dir != "" {
+		lm.ReportDir = dir
+	}
+	store, err := NewReportStoreFromConfig(lm.ReportDir)
+	if err != nil {
+		lm.Fatalf("%s", err)
+	}
+	lm.Store = store
+	lm.Provenance = NewProvenance(viper.ConfigFileUsed(), RedactionPolicyFromConfig())
+	log.Printf("run id: %s, config: %s, git commit: %s", lm.Provenance.RunID, lm.Provenance.ConfigFile, lm.Provenance.GitCommit)
+	for _, handleVal := range suiteCfg.HandleConfigs() {
 		lm.Groups = append(lm.Groups, NewRunner(
 			handleVal.HandleName,
 			lm,
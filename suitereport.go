@@ -0,0 +1,240 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// SuiteReportFileTmpl is a name of suite report in report store, it is kept in subdir to not be taken for a handle report
+	SuiteReportFileTmpl = "suites/suite-%d.json"
+
+	VerdictAborted = "ABORTED"
+)
+
+// LabelSummary is metrics summary of handle label
+type LabelSummary struct {
+	Label    string        `json:"label"`
+	Requests uint64        `json:"requests"`
+	Rate     float64       `json:"rate"`
+	Success  float64       `json:"success"`
+	P50      time.Duration `json:"50th"`
+	P95      time.Duration `json:"95th"`
+	P99      time.Duration `json:"99th"`
+	Max      time.Duration `json:"max"`
+}
+
+// HandleSummary is a result of one handle in suite run
+type HandleSummary struct {
+	Name    string `json:"name"`
+	Verdict string `json:"verdict"`
+	// Report is a name of handle report in report store
+	Report     string    `json:"report"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	RunError   string    `json:"runError,omitempty"`
+	Failed     bool      `json:"failed"`
+	Unreliable bool      `json:"unreliable"`
+	// Degradation is a baseline check result, empty when there is no baseline
+	Degradation         bool                 `json:"degradation"`
+	DegradationCheck    string               `json:"degradationCheck,omitempty"`
+	ThresholdViolations []ThresholdViolation `json:"thresholdViolations,omitempty"`
+	FailedAssertions    []string             `json:"failedAssertions,omitempty"`
+	GuardBreaches       int                  `json:"guardBreaches,omitempty"`
+	Labels              []LabelSummary       `json:"labels"`
+}
+
+// SuiteReport ties together handle reports of one suite run
+type SuiteReport struct {
+	Suite         string    `json:"suite"`
+	Environment   string    `json:"environment"`
+	RunID         string    `json:"runId,omitempty"`
+	Ts            int64     `json:"ts"`
+	ExecutionMode string    `json:"executionMode"`
+	StartedAt     time.Time `json:"startedAt"`
+	FinishedAt    time.Time `json:"finishedAt"`
+	// Verdict is the worst verdict of handles or ABORTED when suite was aborted by guard
+	Verdict          string `json:"verdict"`
+	Degradation      bool   `json:"degradation"`
+	Failed           bool   `json:"failed"`
+	Aborted          bool   `json:"aborted"`
+	AssertionsFailed bool   `json:"assertionsFailed"`
+	// RunError is set when suite run is stopped by signal or fatal error
+	RunError string          `json:"runError,omitempty"`
+	Handles  []HandleSummary `json:"handles"`
+}
+
+// SuiteReportFileName returns suite report name of suite run
+func SuiteReportFileName(ts int64) string {
+	return fmt.Sprintf(SuiteReportFileTmpl, ts)
+}
+
+// NewHandleSummary summarizes handle report
+func NewHandleSummary(name string, ts int64, r *RunReport) HandleSummary {
+	h := HandleSummary{
+		Name:                name,
+		Verdict:             ReportVerdict(r),
+		Report:              ReportFileName(name, ts),
+		StartedAt:           r.StartedAt,
+		FinishedAt:          r.FinishedAt,
+		RunError:            r.RunError,
+		Failed:              r.Failed,
+		Unreliable:          r.Unreliable,
+		Degradation:         r.Degradation,
+		ThresholdViolations: r.ThresholdViolations,
+		GuardBreaches:       len(r.GuardBreaches),
+	}
+	if r.DegradationCheck != nil {
+		h.DegradationCheck = r.DegradationCheck.String()
+	}
+	for _, a := range r.Assertions {
+		if !a.Passed {
+			h.FailedAssertions = append(h.FailedAssertions, a.String())
+		}
+	}
+	for label, m := range r.Metrics {
+		h.Labels = append(h.Labels, LabelSummary{
+			Label:    label,
+			Requests: m.Requests,
+			Rate:     m.Rate,
+			Success:  m.Success,
+			P50:      m.Latencies.P50,
+			P95:      m.Latencies.P95,
+			P99:      m.Latencies.P99,
+			Max:      m.Latencies.Max,
+		})
+	}
+	sort.Slice(h.Labels, func(i, j int) bool { return h.Labels[i].Label < h.Labels[j].Label })
+	return h
+}
+
+// NewSuiteReport summarizes handle reports of suite run stored with ReportTs
+func (m *LoadManager) NewSuiteReport() *SuiteReport {
+	key := m.baselineKey()
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	s := &SuiteReport{
+		Suite:            key.Suite,
+		Environment:      key.Environment,
+		Ts:               m.ReportTs,
+		ExecutionMode:    m.ExecutionMode,
+		StartedAt:        m.StartedAt,
+		FinishedAt:       m.FinishedAt,
+		Verdict:          "OK",
+		Degradation:      m.Degradation,
+		Failed:           m.Failed,
+		Aborted:          m.Aborted,
+		AssertionsFailed: m.AssertionsFailed,
+		RunError:         m.RunError,
+	}
+	if m.Provenance != nil {
+		s.RunID = m.Provenance.RunID
+	}
+	names := make([]string, 0, len(m.Reports))
+	for name := range m.Reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h := NewHandleSummary(name, m.ReportTs, m.Reports[name])
+		if verdictSeverity[h.Verdict] > verdictSeverity[s.Verdict] {
+			s.Verdict = h.Verdict
+		}
+		s.Handles = append(s.Handles, h)
+	}
+	if m.RunError != "" {
+		s.Verdict = "FAILED"
+	}
+	if m.Aborted {
+		s.Verdict = VerdictAborted
+	}
+	return s
+}
+
+// StoreSuiteReport writes suite report next to handle reports and prints summary table
+func (m *LoadManager) StoreSuiteReport() {
+	s := m.NewSuiteReport()
+	m.SuiteReport = s
+	name := SuiteReportFileName(m.ReportTs)
+	log.Printf("writing suite report as %s", name)
+	if err := PutSuiteReport(m.store(), s); err != nil {
+		m.Fatalf("%s", err)
+	}
+	if err := WriteSuiteSummary(os.Stdout, s); err != nil {
+		m.Fatalf("%s", err)
+	}
+}
+
+// PutSuiteReport stores suite report
+func PutSuiteReport(store ReportStore, s *SuiteReport) error {
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	return store.Put(SuiteReportFileName(s.Ts), data)
+}
+
+// GetSuiteReport reads suite report of suite run
+func GetSuiteReport(store ReportStore, ts int64) (*SuiteReport, error) {
+	name := SuiteReportFileName(ts)
+	data, err := store.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	var s SuiteReport
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal suite report %s: %s", name, err)
+	}
+	return &s, nil
+}
+
+// WriteSuiteSummary writes suite report as console table, problems of handles are listed below it
+func WriteSuiteSummary(w io.Writer, s *SuiteReport) error {
+	fmt.Fprintf(w, "suite %s [%s], %s mode, %s - %s: %s\n", s.Suite, s.Environment, s.ExecutionMode,
+		s.StartedAt.Format(time.RFC3339), s.FinishedAt.Format(time.RFC3339), s.Verdict)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "handle\tlabel\tverdict\trequests\trate\tsuccess\tp50\tp95\tp99\tmax\tduration")
+	for _, h := range s.Handles {
+		duration := h.FinishedAt.Sub(h.StartedAt).Round(time.Second)
+		if len(h.Labels) == 0 {
+			fmt.Fprintf(tw, "%s\t-\t%s\t\t\t\t\t\t\t\t%s\n", h.Name, h.Verdict, duration)
+		}
+		for _, l := range h.Labels {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.2f\t%.2f%%\t%s\t%s\t%s\t%s\t%s\n",
+				h.Name, l.Label, h.Verdict, l.Requests, l.Rate, l.Success*100,
+				l.P50.Round(time.Microsecond), l.P95.Round(time.Microsecond), l.P99.Round(time.Microsecond), l.Max.Round(time.Microsecond), duration)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, h := range s.Handles {
+		var problems []string
+		if h.RunError != "" {
+			problems = append(problems, "run error: "+h.RunError)
+		}
+		if h.Degradation && h.DegradationCheck != "" {
+			problems = append(problems, "degradation "+h.DegradationCheck)
+		}
+		for _, v := range h.ThresholdViolations {
+			problems = append(problems, "threshold "+v.String())
+		}
+		for _, a := range h.FailedAssertions {
+			problems = append(problems, "assertion "+a)
+		}
+		if h.GuardBreaches > 0 {
+			problems = append(problems, fmt.Sprintf("%d guard breaches", h.GuardBreaches))
+		}
+		for _, p := range problems {
+			fmt.Fprintf(w, "[ %s ] %s\n", h.Name, strings.TrimSpace(p))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package loadgen
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSuiteReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lm := NewLoadManager()
+	lm.ReportDir = dir
+	lm.BaselineKey = BaselineKey{Suite: "prod-min", Environment: "stage"}
+	lm.ExecutionMode = "parallel"
+	lm.Reports["transfer"] = testRunReport(time.Millisecond)
+	degraded := testRunReport(2 * time.Millisecond)
+	degraded.Degradation = true
+	degraded.ThresholdViolations = []ThresholdViolation{{Label: "transfer", Metric: ThresholdP99, Kind: ThresholdKindAbsolute, Current: 2, Limit: 1}}
+	lm.Reports["balance"] = degraded
+	lm.Degradation = true
+	lm.StoreHandleReports()
+	lm.StoreSuiteReport()
+
+	s, err := GetSuiteReport(lm.store(), lm.ReportTs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Verdict, "DEGRADED"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := len(s.Handles), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := s.Handles[0].Name, "balance"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := s.Handles[1].Labels[0].Requests, uint64(10); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	// suite report is not taken for a handle report
+	byTs, err := storedReportsByTs(lm.store())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(byTs[lm.ReportTs]), 2; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	var out bytes.Buffer
+	if err := WriteSuiteSummary(&out, s); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "[ balance ] threshold transfer p99") {
+		t.Errorf("unexpected summary: %s", out.String())
+	}
+}
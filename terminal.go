@@ -0,0 +1,249 @@
+package loadgen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PhaseWaiting  = "waiting"
+	PhaseRampUp   = "rampup"
+	PhaseAttack   = "attack"
+	PhaseStopping = "stopping"
+	PhaseDone     = "done"
+
+	liveLatencyWindow = 10 * time.Second
+	liveRateWindow    = 5 * time.Second
+	liveErrorsKept    = 3
+	dashboardLogLines = 5
+
+	ansiClearScreen = "\033[H\033[2J"
+)
+
+type liveSample struct {
+	at      time.Time
+	elapsed time.Duration
+	failed  bool
+}
+
+// liveStats holds rolling stats of a runner for live dashboard
+type liveStats struct {
+	mu        sync.Mutex
+	phase     string
+	targetRPS int
+	attackers int
+	samples   []liveSample
+	errors    []string
+}
+
+// HandleSnapshot is a live state of handle shown in dashboard
+type HandleSnapshot struct {
+	Name         string
+	Phase        string
+	TargetRPS    int
+	AchievedRPS  float64
+	Attackers    int
+	P50          time.Duration
+	P95          time.Duration
+	P99          time.Duration
+	ErrorRate    float64
+	LatestErrors []string
+}
+
+func newLiveStats() *liveStats {
+	return &liveStats{phase: PhaseWaiting}
+}
+
+func (s *liveStats) setPhase(phase string) {
+	s.mu.Lock()
+	s.phase = phase
+	s.mu.Unlock()
+}
+
+func (s *liveStats) setTargetRPS(rps int) {
+	s.mu.Lock()
+	s.targetRPS = rps
+	s.mu.Unlock()
+}
+
+func (s *liveStats) setAttackers(n int) {
+	s.mu.Lock()
+	s.attackers = n
+	s.mu.Unlock()
+}
+
+func (s *liveStats) add(r result) {
+	failed := r.doResult.Error != nil || r.doResult.StatusCode >= 400
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, liveSample{at: r.end, elapsed: r.elapsed, failed: failed})
+	s.trim(r.end)
+	if r.doResult.Error != nil {
+		s.errors = append(s.errors, r.doResult.Error.Error())
+		if len(s.errors) > liveErrorsKept {
+			s.errors = s.errors[len(s.errors)-liveErrorsKept:]
+		}
+	}
+}
+
+// trim drops samples out of window, samples are kept only for the window even when dashboard is off
+func (s *liveStats) trim(now time.Time) {
+	cut := 0
+	for cut < len(s.samples) && now.Sub(s.samples[cut].at) > liveLatencyWindow {
+		cut++
+	}
+	s.samples = s.samples[cut:]
+}
+
+// snapshot computes rolling stats of samples in window
+func (s *liveStats) snapshot(name string, now time.Time) HandleSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trim(now)
+	snap := HandleSnapshot{
+		Name:         name,
+		Phase:        s.phase,
+		TargetRPS:    s.targetRPS,
+		Attackers:    s.attackers,
+		LatestErrors: append([]string(nil), s.errors...),
+	}
+	if len(s.samples) == 0 {
+		return snap
+	}
+	latencies := make([]time.Duration, 0, len(s.samples))
+	var failed, recent int
+	for _, smp := range s.samples {
+		latencies = append(latencies, smp.elapsed)
+		if smp.failed {
+			failed++
+		}
+		if now.Sub(smp.at) <= liveRateWindow {
+			recent++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	snap.P50 = latencies[(len(latencies)-1)*50/100]
+	snap.P95 = latencies[(len(latencies)-1)*95/100]
+	snap.P99 = latencies[(len(latencies)-1)*99/100]
+	snap.ErrorRate = float64(failed) / float64(len(s.samples))
+	snap.AchievedRPS = float64(recent) / liveRateWindow.Seconds()
+	return snap
+}
+
+// logRing keeps last log lines to show them in dashboard instead of scrolling terminal
+type logRing struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *logRing) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		l.lines = append(l.lines, line)
+	}
+	if len(l.lines) > dashboardLogLines {
+		l.lines = l.lines[len(l.lines)-dashboardLogLines:]
+	}
+	return len(p), nil
+}
+
+func (l *logRing) last() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+// TerminalDashboard renders live state of all handles in terminal every second
+type TerminalDashboard struct {
+	runners []*Runner
+	out     io.Writer
+	logs    *logRing
+	// logOut is log output before Start, restored on Stop
+	logOut  io.Writer
+	started time.Time
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewTerminalDashboard creates terminal dashboard for runners
+func NewTerminalDashboard(runners []*Runner, out io.Writer) *TerminalDashboard {
+	return &TerminalDashboard{
+		runners: runners,
+		out:     out,
+		logs:    &logRing{},
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start redirects log output to dashboard and starts rendering
+func (d *TerminalDashboard) Start() {
+	if d == nil {
+		return
+	}
+	d.started = time.Now()
+	d.logOut = log.Writer()
+	log.SetOutput(d.logs)
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.render(time.Now())
+			case <-d.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop renders final state and restores log output
+func (d *TerminalDashboard) Stop() {
+	if d == nil {
+		return
+	}
+	close(d.quit)
+	d.wg.Wait()
+	d.render(time.Now())
+	log.SetOutput(d.logOut)
+}
+
+func (d *TerminalDashboard) render(now time.Time) {
+	var b bytes.Buffer
+	b.WriteString(ansiClearScreen)
+	fmt.Fprintf(&b, "loadgen %s elapsed\n\n", now.Sub(d.started).Round(time.Second))
+	fmt.Fprintf(&b, "%-30s %-9s %8s %10s %9s %10s %10s %10s %7s\n",
+		"handle", "phase", "target", "achieved", "attackers", "p50", "p95", "p99", "errors")
+	var errs []string
+	for _, r := range d.runners {
+		s := r.live.snapshot(r.name, now)
+		fmt.Fprintf(&b, "%-30s %-9s %8d %10.1f %9d %10s %10s %10s %6.2f%%\n",
+			s.Name, s.Phase, s.TargetRPS, s.AchievedRPS, s.Attackers,
+			s.P50.Round(time.Microsecond), s.P95.Round(time.Microsecond), s.P99.Round(time.Microsecond), s.ErrorRate*100)
+		for _, e := range s.LatestErrors {
+			errs = append(errs, fmt.Sprintf("[%s] %s", s.Name, e))
+		}
+	}
+	if len(errs) > 0 {
+		b.WriteString("\nlatest errors:\n")
+		for _, e := range errs {
+			fmt.Fprintf(&b, "  %s\n", e)
+		}
+	}
+	if lines := d.logs.last(); len(lines) > 0 {
+		b.WriteString("\nlog:\n")
+		for _, l := range lines {
+			fmt.Fprintf(&b, "  %s\n", l)
+		}
+	}
+	d.out.Write(b.Bytes())
+}
@@ -0,0 +1,81 @@
+package loadgen
+
+import (
+	"bytes"
+	e "errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveStatsSnapshot(t *testing.T) {
+	s := newLiveStats()
+	s.setPhase(PhaseAttack)
+	s.setTargetRPS(10)
+	now := time.Now()
+	// out of latency window
+	s.add(result{end: now.Add(-time.Minute), elapsed: time.Hour})
+	for i := 1; i <= 100; i++ {
+		r := result{end: now, elapsed: time.Duration(i) * time.Millisecond}
+		if i%10 == 0 {
+			r.doResult.Error = e.New("timeout")
+		}
+		s.add(r)
+	}
+	snap := s.snapshot("transfer", now)
+	if got, want := snap.P50, 50*time.Millisecond; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := snap.P99, 99*time.Millisecond; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := snap.ErrorRate, 0.1; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := snap.AchievedRPS, 20.0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := len(snap.LatestErrors), liveErrorsKept; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestLiveStatsTrimsWithoutSnapshot(t *testing.T) {
+	s := newLiveStats()
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		s.add(result{end: start.Add(time.Duration(i) * time.Second)})
+	}
+	if got, want := len(s.samples), int(liveLatencyWindow/time.Second)+1; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestTerminalDashboardRender(t *testing.T) {
+	r := &Runner{name: "transfer"}
+	r.init()
+	r.live.setPhase(PhaseRampUp)
+	var out bytes.Buffer
+	d := NewTerminalDashboard([]*Runner{r}, &out)
+	d.render(time.Now())
+	if !strings.Contains(out.String(), "transfer") || !strings.Contains(out.String(), PhaseRampUp) {
+		t.Errorf("unexpected dashboard output: %s", out.String())
+	}
+}
+
+func TestTerminalDashboardRestoresLogOutput(t *testing.T) {
+	prev := log.Writer()
+	defer log.SetOutput(prev)
+	var embedded bytes.Buffer
+	log.SetOutput(&embedded)
+	var out bytes.Buffer
+	d := NewTerminalDashboard(nil, &out)
+	d.Start()
+	log.Print("during run")
+	d.Stop()
+	log.Print("after run")
+	if got := embedded.String(); strings.Contains(got, "during run") || !strings.Contains(got, "after run") {
+		t.Errorf("unexpected log output: %s", got)
+	}
+}
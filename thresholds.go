@@ -0,0 +1,262 @@
+package loadgen
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	ThresholdP50         = "p50"
+	ThresholdP95         = "p95"
+	ThresholdP99         = "p99"
+	ThresholdMax         = "max"
+	ThresholdErrorRate   = "error_rate"
+	ThresholdSuccessRate = "success_rate"
+	ThresholdThroughput  = "throughput"
+
+	ThresholdKindRelative = "relative"
+	ThresholdKindAbsolute = "absolute"
+)
+
+var thresholdQuantiles = map[string]float64{
+	ThresholdP50: 0.5,
+	ThresholdP95: 0.95,
+	ThresholdP99: 0.99,
+	ThresholdMax: 1,
+}
+
+// Threshold is a degradation limit of one metric or SLO assertion expression, e.g.
+//   - metric: p99
+//     label: transfer_noisy
+//     relative: 1.5
+//     absolute: 300
+//   - p99 < 300ms
+//   - expr: rate >= 0.95*target
+//     abort_on_fail: true
+type Threshold struct {
+	// Expr is SLO assertion, see ParseAssertion, string list items are decoded as Expr
+	Expr string `mapstructure:"expr"`
+	// AbortOnFail stops the handle when assertion fails in sliding window
+	AbortOnFail bool `mapstructure:"abort_on_fail"`
+	// WindowSec is sliding window of assertion, default is 10 seconds
+	WindowSec int `mapstructure:"window_sec"`
+	// Label of the request, empty applies to every label without its own threshold for the metric
+	Label string `mapstructure:"label"`
+	// Metric is one of p50, p95, p99, max, error_rate, success_rate, throughput
+	Metric string `mapstructure:"metric"`
+	// Relative is max allowed ratio of worsening to baseline runs, e.g. 1.2 allows 20% higher latency
+	// or 1.2 times lower throughput, zero disables the check
+	Relative float64 `mapstructure:"relative"`
+	// Absolute is a hard limit: max latency in milliseconds, max error rate, min success rate (0..1)
+	// or min throughput in requests per second, zero disables the check
+	Absolute float64 `mapstructure:"absolute"`
+}
+
+func (t Threshold) String() string {
+	if t.Expr != "" {
+		return t.Expr
+	}
+	s := t.Metric
+	if t.Label != "" {
+		s = t.Label + " " + s
+	}
+	if t.Relative > 0 {
+		s += fmt.Sprintf(" relative %g", t.Relative)
+	}
+	if t.Absolute > 0 {
+		s += fmt.Sprintf(" absolute %g", t.Absolute)
+	}
+	return s
+}
+
+// ThresholdViolation is a metric of handle label which reached its threshold
+type ThresholdViolation struct {
+	Label  string `json:"label"`
+	Metric string `json:"metric"`
+	// Kind is relative or absolute
+	Kind     string  `json:"kind"`
+	Current  float64 `json:"current"`
+	Baseline float64 `json:"baseline,omitempty"`
+	Ratio    float64 `json:"ratio,omitempty"`
+	Limit    float64 `json:"limit"`
+	// PValue of Mann-Whitney test for relative latency violations
+	PValue *float64 `json:"pValue,omitempty"`
+}
+
+func (v ThresholdViolation) String() string {
+	if v.Kind == ThresholdKindRelative {
+		s := fmt.Sprintf("%s %s: %s -> %s, ratio %.3f >= %.3f", v.Label, v.Metric, v.format(v.Baseline), v.format(v.Current), v.Ratio, v.Limit)
+		if v.PValue != nil {
+			s += fmt.Sprintf(", p-value %.4g", *v.PValue)
+		}
+		return s
+	}
+	op := ">"
+	if !higherIsWorse(v.Metric) {
+		op = "<"
+	}
+	return fmt.Sprintf("%s %s: %s %s %s", v.Label, v.Metric, v.format(v.Current), op, v.format(v.Limit))
+}
+
+func (v ThresholdViolation) format(value float64) string {
+	switch v.Metric {
+	case ThresholdErrorRate, ThresholdSuccessRate:
+		return fmt.Sprintf("%.2f%%", value*100)
+	case ThresholdThroughput:
+		return fmt.Sprintf("%.2frps", value)
+	}
+	return fmt.Sprintf("%.3fms", value)
+}
+
+func higherIsWorse(metric string) bool {
+	return metric != ThresholdSuccessRate && metric != ThresholdThroughput
+}
+
+// ValidateThresholds returns a list of problems with thresholds
+func ValidateThresholds(ts []Threshold) (list []string) {
+	for i, t := range ts {
+		if t.Expr != "" {
+			if _, err := ParseAssertion(t.Expr); err != nil {
+				list = append(list, fmt.Sprintf("threshold %d: %s", i, err))
+			}
+			if t.WindowSec < 0 {
+				list = append(list, fmt.Sprintf("threshold %d: window must not be negative", i))
+			}
+			continue
+		}
+		switch t.Metric {
+		case ThresholdP50, ThresholdP95, ThresholdP99, ThresholdMax, ThresholdErrorRate, ThresholdSuccessRate, ThresholdThroughput:
+		default:
+			list = append(list, fmt.Sprintf("threshold %d: unknown metric [%s], possible values are {p50,p95,p99,max,error_rate,success_rate,throughput}", i, t.Metric))
+		}
+		if t.Relative < 0 || t.Absolute < 0 {
+			list = append(list, fmt.Sprintf("threshold %d: limits must not be negative", i))
+		}
+		if t.Relative == 0 && t.Absolute == 0 {
+			list = append(list, fmt.Sprintf("threshold %d: set relative or absolute limit", i))
+		}
+	}
+	return
+}
+
+// metricThresholds returns degradation thresholds of metrics, assertions are checked by sloMonitor
+func metricThresholds(ts []Threshold) []Threshold {
+	var res []Threshold
+	for _, t := range ts {
+		if t.Expr == "" {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// thresholdsForLabel returns metric thresholds of the label, label specific threshold overrides the generic one
+func thresholdsForLabel(ts []Threshold, label string) []Threshold {
+	ts = metricThresholds(ts)
+	byMetric := map[string]Threshold{}
+	for _, t := range ts {
+		if t.Label == "" {
+			if _, ok := byMetric[t.Metric]; !ok {
+				byMetric[t.Metric] = t
+			}
+		}
+	}
+	for _, t := range ts {
+		if t.Label == label {
+			byMetric[t.Metric] = t
+		}
+	}
+	res := make([]Threshold, 0, len(byMetric))
+	for _, t := range byMetric {
+		res = append(res, t)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Metric < res[j].Metric })
+	return res
+}
+
+func errorRate(m *Metrics) float64 {
+	if m.Requests == 0 {
+		return 0
+	}
+	return 1 - m.Success
+}
+
+// failedRequests returns number of failed requests
+func failedRequests(m *Metrics) uint64 {
+	return uint64(float64(m.Requests)*errorRate(m) + 0.5)
+}
+
+func thresholdValue(m *Metrics, metric string) float64 {
+	switch metric {
+	case ThresholdErrorRate:
+		return errorRate(m)
+	case ThresholdSuccessRate:
+		return m.Success
+	case ThresholdThroughput:
+		return m.Rate
+	}
+	return durationMs(latencyQuantile(m, thresholdQuantiles[metric]))
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// CheckThresholds checks label metrics against thresholds, baselines are metrics of the label
+// in previous successful runs, relative limits are not checked without them
+func CheckThresholds(label string, current *Metrics, baselines []*Metrics, ts []Threshold, alpha float64) []ThresholdViolation {
+	var res []ThresholdViolation
+	for _, t := range thresholdsForLabel(ts, label) {
+		value := thresholdValue(current, t.Metric)
+		if t.Absolute > 0 {
+			violated := value > t.Absolute
+			if !higherIsWorse(t.Metric) {
+				violated = value < t.Absolute
+			}
+			if violated {
+				res = append(res, ThresholdViolation{Label: label, Metric: t.Metric, Kind: ThresholdKindAbsolute, Current: value, Limit: t.Absolute})
+			}
+		}
+		if t.Relative == 0 || len(baselines) == 0 {
+			continue
+		}
+		if q, ok := thresholdQuantiles[t.Metric]; ok {
+			lc := newLatencyCheck(q, current, baselines, alpha)
+			if lc.degraded(t.Relative) {
+				res = append(res, ThresholdViolation{
+					Label:    label,
+					Metric:   t.Metric,
+					Kind:     ThresholdKindRelative,
+					Current:  durationMs(lc.current),
+					Baseline: durationMs(lc.baseline),
+					Ratio:    lc.ratio,
+					Limit:    t.Relative,
+					PValue:   lc.pValue,
+				})
+			}
+			continue
+		}
+		values := make([]float64, 0, len(baselines))
+		for _, b := range baselines {
+			values = append(values, thresholdValue(b, t.Metric))
+		}
+		baseline := median(values)
+		worse, base := value, baseline
+		if !higherIsWorse(t.Metric) {
+			worse, base = baseline, value
+		}
+		if base <= 0 {
+			// no errors in baseline runs, only absolute limit makes sense for error rate,
+			// zero success or throughput is a violation of any relative limit
+			if !higherIsWorse(t.Metric) && worse > 0 {
+				res = append(res, ThresholdViolation{Label: label, Metric: t.Metric, Kind: ThresholdKindRelative, Current: value, Baseline: baseline, Limit: t.Relative})
+			}
+			continue
+		}
+		if ratio := worse / base; ratio >= t.Relative {
+			res = append(res, ThresholdViolation{Label: label, Metric: t.Metric, Kind: ThresholdKindRelative, Current: value, Baseline: baseline, Ratio: ratio, Limit: t.Relative})
+		}
+	}
+	return res
+}
@@ -0,0 +1,80 @@
+package loadgen
+
+import (
+	"testing"
+	"time"
+)
+
+func testThresholdMetrics(p99 time.Duration, success, rate float64) *Metrics {
+	return &Metrics{
+		Requests:  100,
+		Success:   success,
+		Rate:      rate,
+		Latencies: LatencyMetrics{P50: p99 / 2, P95: p99, P99: p99, Max: p99},
+	}
+}
+
+func TestThresholdsForLabel(t *testing.T) {
+	ts := []Threshold{
+		{Metric: ThresholdP99, Relative: 1.2},
+		{Label: "noisy", Metric: ThresholdP99, Relative: 2},
+		{Metric: ThresholdErrorRate, Absolute: 0.01},
+	}
+	got := thresholdsForLabel(ts, "noisy")
+	if got, want := len(got), 2; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := got[1].Relative, 2.0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := thresholdsForLabel(ts, "critical")[1].Relative, 1.2; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	ts := []Threshold{
+		{Metric: ThresholdP99, Relative: 1.2, Absolute: 250},
+		{Label: "noisy", Metric: ThresholdP99, Relative: 2},
+		{Metric: ThresholdSuccessRate, Absolute: 0.99},
+		{Metric: ThresholdThroughput, Relative: 1.5},
+	}
+	baselines := []*Metrics{testThresholdMetrics(100*time.Millisecond, 1, 100)}
+	current := testThresholdMetrics(300*time.Millisecond, 0.95, 50)
+
+	vs := CheckThresholds("critical", current, baselines, ts, 0.01)
+	if got, want := len(vs), 4; got != want {
+		t.Fatalf("got %v want %v: %v", got, want, vs)
+	}
+	if got, want := vs[0].Kind, ThresholdKindAbsolute; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := vs[1].Ratio, 3.0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := vs[3].Metric, ThresholdThroughput; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := vs[3].Ratio, 2.0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	looser := testThresholdMetrics(180*time.Millisecond, 1, 100)
+	if got, want := len(CheckThresholds("noisy", looser, baselines, ts, 0.01)), 0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := len(CheckThresholds("critical", looser, baselines, ts, 0.01)), 1; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	// relative limits are not checked without baseline
+	if got, want := len(CheckThresholds("critical", current, nil, ts, 0.01)), 2; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestValidateThresholds(t *testing.T) {
+	msg := ValidateThresholds([]Threshold{{Metric: "p42", Relative: 1.2}, {Metric: ThresholdMax}})
+	if got, want := len(msg), 2; got != want {
+		t.Errorf("got %v want %v: %v", got, want, msg)
+	}
+}
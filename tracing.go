@@ -0,0 +1,383 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	TraceParentHeader = "traceparent"
+	otlpTracesPath    = "/v1/traces"
+
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	spanStatusError = 2
+
+	defaultTracingBatchSize   = 512
+	defaultTracingQueueSize   = 8192
+	defaultTracingServiceName = "loadgen"
+)
+
+type spanCtxKey struct{}
+
+// Span is a single traced operation, exported in OTLP format
+type Span struct {
+	tracer     *Tracer
+	mu         sync.Mutex
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	errMsg     string
+	ended      bool
+}
+
+// SetName renames span, used when the name is known only after the operation, e.g. request label
+func (s *Span) SetName(name string) {
+	if s == nil || name == "" {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttribute sets span attribute, value must be a string, bool, int, int64 or float64
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attributes[key] = value
+	s.mu.Unlock()
+}
+
+// SetError marks span as failed
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.errMsg = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes span and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+// TraceParent returns w3c traceparent header value for span
+func (s *Span) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]))
+}
+
+// SpanFromContext returns current span or nil if ctx is not traced
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanCtxKey{}).(*Span)
+	return s
+}
+
+// Tracer creates spans and exports them in batches to OTLP/HTTP collector
+type Tracer struct {
+	url         string
+	serviceName string
+	batchSize   int
+	interval    time.Duration
+	client      *http.Client
+	queue       chan *Span
+	flush       chan chan struct{}
+	done        chan struct{}
+	stop        sync.Once
+	dropped     int64
+}
+
+// NewTracer creates tracer exporting spans to OTLP/HTTP collector at url, e.g. http://localhost:4318
+func NewTracer(url string, serviceName string, flushInterval time.Duration) *Tracer {
+	if serviceName == "" {
+		serviceName = defaultTracingServiceName
+	}
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+	t := &Tracer{
+		url:         strings.TrimRight(url, "/") + otlpTracesPath,
+		serviceName: serviceName,
+		batchSize:   defaultTracingBatchSize,
+		interval:    flushInterval,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		queue:       make(chan *Span, defaultTracingQueueSize),
+		flush:       make(chan chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go t.exportLoop()
+	return t
+}
+
+// InitTracing creates tracer of suite run if tracing.otlp_url is set in config, nil otherwise
+func InitTracing() *Tracer {
+	url := viper.GetString("tracing.otlp_url")
+	if url == "" {
+		return nil
+	}
+	log.Printf("[ tracing ] exporting spans to %s\n", url)
+	return NewTracer(
+		url,
+		viper.GetString("tracing.service_name"),
+		time.Duration(viper.GetInt("tracing.flushDurationSec"))*time.Second,
+	)
+}
+
+// Start starts new span, child of span in ctx if any, nil tracer returns nil span
+func (t *Tracer) Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: map[string]interface{}{},
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		_, _ = rand.Read(s.traceID[:])
+	}
+	_, _ = rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanCtxKey{}, s), s
+}
+
+// Shutdown exports all queued spans and stops exporter
+func (t *Tracer) Shutdown() {
+	if t == nil {
+		return
+	}
+	t.stop.Do(func() {
+		flushed := make(chan struct{})
+		t.flush <- flushed
+		<-flushed
+		close(t.done)
+		if dropped := atomic.LoadInt64(&t.dropped); dropped > 0 {
+			log.Printf("[ tracing ] spans dropped because export queue was full: %d\n", dropped)
+		}
+	})
+}
+
+func (t *Tracer) enqueue(s *Span) {
+	select {
+	case t.queue <- s:
+	default:
+		// never block attackers because of slow collector
+		atomic.AddInt64(&t.dropped, 1)
+	}
+}
+
+func (t *Tracer) exportLoop() {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, t.batchSize)
+	for {
+		select {
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) >= t.batchSize {
+				t.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			t.export(batch)
+			batch = batch[:0]
+		case flushed := <-t.flush:
+			for len(t.queue) > 0 {
+				batch = append(batch, <-t.queue)
+			}
+			t.export(batch)
+			batch = batch[:0]
+			close(flushed)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *Tracer) export(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	data, err := json.Marshal(t.otlpPayload(batch))
+	if err != nil {
+		log.Printf("[ tracing ] failed to marshal spans: %v\n", err)
+		return
+	}
+	resp, err := t.client.Post(t.url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		log.Printf("[ tracing ] failed to export spans: %v\n", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("[ tracing ] collector responded with status: %d\n", resp.StatusCode)
+	}
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+func (t *Tracer) otlpPayload(batch []*Span) map[string]interface{} {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		s.mu.Lock()
+		out := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parentID != [8]byte{} {
+			out.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		for k, v := range s.attributes {
+			out.Attributes = append(out.Attributes, otlpKeyValue{Key: k, Value: otlpValue(v)})
+		}
+		if s.errMsg != "" {
+			out.Status = otlpStatus{Code: spanStatusError, Message: s.errMsg}
+		}
+		s.mu.Unlock()
+		spans = append(spans, out)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []otlpKeyValue{
+						{Key: "service.name", Value: otlpValue(t.serviceName)},
+					},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "loadgen"},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpValue(v interface{}) map[string]interface{} {
+	switch val := v.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": val}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(val)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": val}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(val)}
+	}
+}
+
+// spanURL returns url without query and userinfo, they often contain tokens
+func spanURL(u *url.URL) string {
+	stripped := *u
+	stripped.User = nil
+	stripped.RawQuery = ""
+	stripped.ForceQuery = false
+	stripped.Fragment = ""
+	return stripped.String()
+}
+
+// TracingTransport creates client span for every request made in traced Do and propagates trace context in headers
+type TracingTransport struct {
+	r http.RoundTripper
+}
+
+func (t *TracingTransport) RoundTrip(h *http.Request) (*http.Response, error) {
+	parent := SpanFromContext(h.Context())
+	if parent == nil {
+		return t.r.RoundTrip(h)
+	}
+	_, span := parent.tracer.Start(h.Context(), h.Method+" "+h.URL.Path, spanKindClient)
+	defer span.End()
+	span.SetAttribute("http.method", h.Method)
+	span.SetAttribute("http.url", spanURL(h.URL))
+	// request must not be modified by RoundTripper
+	h = cloneRequestHeaders(h)
+	h.Header.Set(TraceParentHeader, span.TraceParent())
+	resp, err := t.r.RoundTrip(h)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		span.SetError(fmt.Errorf("status code: %d", resp.StatusCode))
+	}
+	return resp, nil
+}
+
+func cloneRequestHeaders(h *http.Request) *http.Request {
+	r := new(http.Request)
+	*r = *h
+	r.Header = make(http.Header, len(h.Header))
+	for k, v := range h.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+	return r
+}
@@ -0,0 +1,115 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type collectedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Attributes   []struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue string `json:"stringValue"`
+		} `json:"value"`
+	} `json:"attributes"`
+}
+
+func TestTracingPropagation(t *testing.T) {
+	var mu sync.Mutex
+	var spans []collectedSpan
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != otlpTracesPath {
+			t.Errorf("got path %s want %s", req.URL.Path, otlpTracesPath)
+		}
+		var payload struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []collectedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range payload.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	defer collector.Close()
+
+	var traceParent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		traceParent = req.Header.Get(TraceParentHeader)
+	}))
+	defer backend.Close()
+
+	// client created before tracer is traced too
+	client := NewLoggingHTTPClient(false, 5)
+	tr := NewTracer(collector.URL, "test", time.Hour)
+	ctx, root := tr.Start(context.Background(), "Do", spanKindInternal)
+	req, _ := http.NewRequest("GET", backend.URL+"/api?token=secret", nil)
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	root.SetName("get_api")
+	root.End()
+	tr.Shutdown()
+
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans want 2", len(spans))
+	}
+	child, parent := spans[0], spans[1]
+	if got, want := parent.Name, "get_api"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := child.TraceID, parent.TraceID; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := child.ParentSpanID, parent.SpanID; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := traceParent, "00-"+child.TraceID+"-"+child.SpanID+"-01"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	var exportedURL string
+	for _, a := range child.Attributes {
+		if a.Key == "http.url" {
+			exportedURL = a.Value.StringValue
+		}
+	}
+	if got, want := exportedURL, backend.URL+"/api"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if req.Header.Get(TraceParentHeader) != "" {
+		t.Error("original request must not be modified")
+	}
+}
+
+func TestInitTracingAfterShutdown(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("tracing.otlp_url", "http://localhost:4318")
+	first := InitTracing()
+	first.Shutdown()
+	second := InitTracing()
+	defer second.Shutdown()
+	if second == first {
+		t.Error("expected new tracer for the next suite run")
+	}
+}
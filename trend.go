@@ -0,0 +1,375 @@
+package loadgen
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TrendFormatCSV  = "csv"
+	TrendFormatJSON = "json"
+	TrendFormatHTML = "html"
+)
+
+// TrendPoint is metrics of one handle label in one suite run
+type TrendPoint struct {
+	// Ts is the suite run timestamp from report name
+	Ts       int64     `json:"ts"`
+	Time     time.Time `json:"time"`
+	Handle   string    `json:"handle"`
+	Label    string    `json:"label"`
+	P50      float64   `json:"p50Ms"`
+	P95      float64   `json:"p95Ms"`
+	P99      float64   `json:"p99Ms"`
+	Rate     float64   `json:"rate"`
+	Requests uint64    `json:"requests"`
+	Errors   uint64    `json:"errors"`
+	// ErrorRate is a share of failed requests
+	ErrorRate float64 `json:"errorRate"`
+	Verdict   string  `json:"verdict"`
+	// RunID and GitCommit are from report provenance, empty for older reports
+	RunID     string `json:"runId,omitempty"`
+	GitCommit string `json:"gitCommit,omitempty"`
+}
+
+// TrendFilter selects runs for trend, empty fields match everything
+type TrendFilter struct {
+	Handle string
+	Label  string
+	Since  time.Time
+}
+
+// TrendDrift is a linear drift of metrics of one handle label, in percents of the first fitted value per week
+type TrendDrift struct {
+	Handle string  `json:"handle"`
+	Label  string  `json:"label"`
+	Runs   int     `json:"runs"`
+	Days   float64 `json:"days"`
+	P50    float64 `json:"p50PercentPerWeek"`
+	P95    float64 `json:"p95PercentPerWeek"`
+	P99    float64 `json:"p99PercentPerWeek"`
+	Rate   float64 `json:"ratePercentPerWeek"`
+}
+
+func (d TrendDrift) String() string {
+	return fmt.Sprintf("%s %s: p50 %+.2f%%/week, p95 %+.2f%%/week, p99 %+.2f%%/week, rate %+.2f%%/week over %d runs in %.1f days",
+		d.Handle, d.Label, d.P50, d.P95, d.P99, d.Rate, d.Runs, d.Days)
+}
+
+// LoadTrend reads all handle reports in store and returns points sorted by run, handle and label
+func LoadTrend(s ReportStore, f TrendFilter) ([]TrendPoint, error) {
+	byTs, err := storedReportsByTs(s)
+	if err != nil {
+		return nil, err
+	}
+	var points []TrendPoint
+	for ts, handles := range byTs {
+		if !f.Since.IsZero() && time.Unix(ts, 0).Before(f.Since) {
+			continue
+		}
+		for _, h := range handles {
+			if f.Handle != "" && h != f.Handle {
+				continue
+			}
+			r, err := GetRunReport(s, h, ts)
+			if err != nil {
+				return nil, err
+			}
+			for label, m := range r.Metrics {
+				if f.Label != "" && label != f.Label {
+					continue
+				}
+				points = append(points, newTrendPoint(ts, h, label, r, m))
+			}
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		a, b := points[i], points[j]
+		if a.Ts != b.Ts {
+			return a.Ts < b.Ts
+		}
+		if a.Handle != b.Handle {
+			return a.Handle < b.Handle
+		}
+		return a.Label < b.Label
+	})
+	return points, nil
+}
+
+func newTrendPoint(ts int64, handle, label string, r *RunReport, m *Metrics) TrendPoint {
+	p := TrendPoint{
+		Ts:        ts,
+		Time:      time.Unix(ts, 0).UTC(),
+		Handle:    handle,
+		Label:     label,
+		P50:       durationMs(m.Latencies.P50),
+		P95:       durationMs(m.Latencies.P95),
+		P99:       durationMs(m.Latencies.P99),
+		Rate:      m.Rate,
+		Requests:  m.Requests,
+		ErrorRate: errorRate(m),
+		Verdict:   ReportVerdict(r),
+	}
+	p.Errors = failedRequests(m)
+	if r.Provenance != nil {
+		p.RunID = r.Provenance.RunID
+		p.GitCommit = r.Provenance.GitCommit
+	}
+	return p
+}
+
+// trendSeries groups points by handle and label, keys are "handle label"
+func trendSeries(points []TrendPoint) ([]string, map[string][]TrendPoint) {
+	series := map[string][]TrendPoint{}
+	var keys []string
+	for _, p := range points {
+		key := p.Handle + " " + p.Label
+		if _, ok := series[key]; !ok {
+			keys = append(keys, key)
+		}
+		series[key] = append(series[key], p)
+	}
+	sort.Strings(keys)
+	return keys, series
+}
+
+// TrendDrifts fits a line to every metric of every handle label, labels with less than 3 runs are skipped
+func TrendDrifts(points []TrendPoint) []TrendDrift {
+	keys, series := trendSeries(points)
+	var res []TrendDrift
+	for _, key := range keys {
+		ps := series[key]
+		if len(ps) < 3 {
+			continue
+		}
+		days := make([]float64, len(ps))
+		for i, p := range ps {
+			days[i] = float64(p.Ts-ps[0].Ts) / float64(24*60*60)
+		}
+		values := func(v func(p TrendPoint) float64) []float64 {
+			vs := make([]float64, len(ps))
+			for i, p := range ps {
+				vs[i] = v(p)
+			}
+			return vs
+		}
+		res = append(res, TrendDrift{
+			Handle: ps[0].Handle,
+			Label:  ps[0].Label,
+			Runs:   len(ps),
+			Days:   days[len(days)-1],
+			P50:    weeklyDrift(days, values(func(p TrendPoint) float64 { return p.P50 })),
+			P95:    weeklyDrift(days, values(func(p TrendPoint) float64 { return p.P95 })),
+			P99:    weeklyDrift(days, values(func(p TrendPoint) float64 { return p.P99 })),
+			Rate:   weeklyDrift(days, values(func(p TrendPoint) float64 { return p.Rate })),
+		})
+	}
+	return res
+}
+
+// weeklyDrift returns least squares slope in percents of the fitted value at the first run per 7 days
+func weeklyDrift(x, y []float64) float64 {
+	n := float64(len(x))
+	var sx, sy, sxx, sxy float64
+	for i := range x {
+		sx += x[i]
+		sy += y[i]
+		sxx += x[i] * x[i]
+		sxy += x[i] * y[i]
+	}
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return 0
+	}
+	slope := (n*sxy - sx*sy) / den
+	intercept := (sy - slope*sx) / n
+	if intercept <= 0 {
+		return 0
+	}
+	return slope * 7 / intercept * 100
+}
+
+var trendCSVHeader = []string{"ts", "time", "handle", "label", "p50_ms", "p95_ms", "p99_ms", "rate", "requests", "errors", "error_rate", "verdict", "run_id", "git_commit"}
+
+// WriteTrend writes trend series as csv, json or self-contained html page with charts
+func WriteTrend(w io.Writer, points []TrendPoint, format string, title string) error {
+	switch format {
+	case TrendFormatCSV, "":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(trendCSVHeader); err != nil {
+			return err
+		}
+		for _, p := range points {
+			err := cw.Write([]string{
+				strconv.FormatInt(p.Ts, 10),
+				p.Time.Format(time.RFC3339),
+				p.Handle,
+				p.Label,
+				strconv.FormatFloat(p.P50, 'f', 3, 64),
+				strconv.FormatFloat(p.P95, 'f', 3, 64),
+				strconv.FormatFloat(p.P99, 'f', 3, 64),
+				strconv.FormatFloat(p.Rate, 'f', 3, 64),
+				strconv.FormatUint(p.Requests, 10),
+				strconv.FormatUint(p.Errors, 10),
+				strconv.FormatFloat(p.ErrorRate, 'f', 6, 64),
+				p.Verdict,
+				p.RunID,
+				p.GitCommit,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case TrendFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Points []TrendPoint `json:"points"`
+			Drifts []TrendDrift `json:"drifts"`
+		}{points, TrendDrifts(points)})
+	case TrendFormatHTML:
+		return writeTrendHTML(w, points, title)
+	default:
+		return fmt.Errorf("unknown format [%s], possible values are {csv,json,html}", format)
+	}
+}
+
+type htmlTrend struct {
+	Name         string
+	Runs         int
+	LatencyChart template.HTML
+	RateChart    template.HTML
+	ErrorsChart  template.HTML
+}
+
+type htmlTrendReport struct {
+	Title       string
+	GeneratedAt time.Time
+	From        time.Time
+	To          time.Time
+	Drifts      []TrendDrift
+	Series      []htmlTrend
+}
+
+func writeTrendHTML(w io.Writer, points []TrendPoint, title string) error {
+	page := htmlTrendReport{Title: title, GeneratedAt: time.Now(), Drifts: TrendDrifts(points)}
+	if len(points) > 0 {
+		page.From = points[0].Time
+		page.To = points[len(points)-1].Time
+	}
+	keys, series := trendSeries(points)
+	for _, key := range keys {
+		ps := series[key]
+		p50 := chartSeries{Name: "p50"}
+		p95 := chartSeries{Name: "p95"}
+		p99 := chartSeries{Name: "p99"}
+		rate := chartSeries{Name: "rate"}
+		errs := chartSeries{Name: "error rate, %"}
+		for _, p := range ps {
+			x := float64(p.Ts-points[0].Ts) / float64(24*60*60)
+			p50.Points = append(p50.Points, chartPoint{x, p.P50})
+			p95.Points = append(p95.Points, chartPoint{x, p.P95})
+			p99.Points = append(p99.Points, chartPoint{x, p.P99})
+			rate.Points = append(rate.Points, chartPoint{x, p.Rate})
+			errs.Points = append(errs.Points, chartPoint{x, p.ErrorRate * 100})
+		}
+		page.Series = append(page.Series, htmlTrend{
+			Name:         key,
+			Runs:         len(ps),
+			LatencyChart: svgLineChart([]chartSeries{p50, p95, p99}, "ms", "d"),
+			RateChart:    svgLineChart([]chartSeries{rate}, "", "d"),
+			ErrorsChart:  svgLineChart([]chartSeries{errs}, "", "d"),
+		})
+	}
+	return htmlTrendTmpl.Execute(w, page)
+}
+
+var htmlTrendTmpl = template.Must(template.New("trend").Funcs(template.FuncMap{
+	"time": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 20px; color: #222; }
+table { border-collapse: collapse; margin-bottom: 16px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child, th:nth-child(2), td:nth-child(2) { text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated at {{time .GeneratedAt}}, runs from {{time .From}} to {{time .To}}, x axis is days since the first run</p>
+{{with .Drifts}}
+<h2>Drift, percents per week</h2>
+<table>
+<tr><th>handle</th><th>label</th><th>runs</th><th>days</th><th>p50</th><th>p95</th><th>p99</th><th>rate</th></tr>
+{{range .}}
+<tr><td>{{.Handle}}</td><td>{{.Label}}</td><td>{{.Runs}}</td><td>{{printf "%.1f" .Days}}</td><td>{{printf "%+.2f" .P50}}</td><td>{{printf "%+.2f" .P95}}</td><td>{{printf "%+.2f" .P99}}</td><td>{{printf "%+.2f" .Rate}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{range .Series}}
+<h2>{{.Name}}, {{.Runs}} runs</h2>
+<h3>Latency percentiles</h3>
+{{.LatencyChart}}
+<h3>Throughput, requests per second</h3>
+{{.RateChart}}
+<h3>Error rate</h3>
+{{.ErrorsChart}}
+{{end}}
+</body>
+</html>
+`))
+
+func runTrendCommand(args []string) {
+	fs := newCommandFlagSet("trend")
+	dir := fs.String("dir", filepath.Join("load", "reports"), "reports dir of filesystem report store")
+	cfgPath := fs.String("config", "", "suite config with reports.store settings, filesystem store in -dir is used when empty")
+	handle := fs.String("handle", "", "handle name, all handles when empty")
+	label := fs.String("label", "", "label name, all labels when empty")
+	since := fs.Duration("since", 0, "only runs not older than duration, e.g. 720h, all runs when zero")
+	format := fs.String("format", TrendFormatCSV, "output format {csv,json,html}")
+	out := fs.String("o", "", "output file, stdout when empty")
+	title := fs.String("title", "Load test trend", "html report title")
+	parseCommandArgs(fs, args, 0)
+
+	f := TrendFilter{Handle: *handle, Label: *label}
+	if *since > 0 {
+		f.Since = time.Now().Add(-*since)
+	}
+	points, err := LoadTrend(commandReportStore(*dir, *cfgPath), f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := WriteTrend(w, points, strings.ToLower(*format), *title); err != nil {
+		log.Fatal(err)
+	}
+	if *out != "" {
+		log.Printf("trend of %d points written to %s", len(points), *out)
+	}
+}
@@ -0,0 +1,76 @@
+package loadgen
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	const day = int64(24 * 60 * 60)
+	start := int64(1577836800)
+	// p50 grows by 1ms a week starting from 10ms
+	for i := int64(0); i < 4; i++ {
+		writeTestReport(t, dir, "transfer", start+i*7*day, testRunReport(10*time.Millisecond+time.Duration(i)*time.Millisecond))
+	}
+	writeTestReport(t, dir, "balance", start, testRunReport(time.Millisecond))
+
+	points, err := LoadTrend(NewFileReportStore(dir), TrendFilter{Handle: "transfer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(points), 4; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := points[3].Ts, start+21*day; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	drifts := TrendDrifts(points)
+	if got, want := len(drifts), 1; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := math.Round(drifts[0].P50), 10.0; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	var out bytes.Buffer
+	if err := WriteTrend(&out, points, TrendFormatCSV, ""); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(rows), 5; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got, want := rows[4][4], "13.000"; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	since, err := LoadTrend(NewFileReportStore(dir), TrendFilter{Since: time.Unix(start+day, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(since), 3; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	out.Reset()
+	if err := WriteTrend(&out, points, TrendFormatHTML, "trend"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "transfer transfer, 4 runs") {
+		t.Errorf("unexpected html: %s", out.String())
+	}
+}
@@ -0,0 +1,172 @@
+package loadgen
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// secondsKeyAliases are handle duration keys without _sec suffix, e.g. attack_time: 4h
+var secondsKeyAliases = map[string]string{
+	"attack_time":  "attack_time_sec",
+	"ramp_up":      "ramp_up_sec",
+	"do_timeout":   "do_timeout_sec",
+	"start_offset": "start_offset_sec",
+}
+
+// ParseSeconds parses Go duration like 90s or 2h, or a bare number of seconds, into whole seconds
+func ParseSeconds(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad duration [%s], use seconds or Go duration like 90s, 15m or 4h", s)
+	}
+	if d%time.Second != 0 {
+		return 0, fmt.Errorf("duration [%s] is not a whole number of seconds", s)
+	}
+	return int(d / time.Second), nil
+}
+
+// ParseRate parses rate like 50/s, 300/m or 100/10s, or a bare number of requests per second, into requests per second,
+// rate limiter of handles works in whole requests per second, so fractional rates like 30/m or 100/m are rejected
+func ParseRate(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	parts := strings.SplitN(s, "/", 2)
+	n, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad rate [%s], use requests per second or rate like 50/s or 300/m", s)
+	}
+	per := time.Second
+	if len(parts) == 2 {
+		unit := strings.TrimSpace(parts[1])
+		if unit != "" && (unit[0] < '0' || unit[0] > '9') {
+			unit = "1" + unit
+		}
+		per, err = time.ParseDuration(unit)
+		if err != nil || per <= 0 {
+			return 0, fmt.Errorf("bad rate [%s], use requests per second or rate like 50/s or 300/m", s)
+		}
+	}
+	rps := n / per.Seconds()
+	if rps != float64(int(rps)) {
+		return 0, fmt.Errorf("rate [%s] is %.4g requests per second, only whole requests per second are supported, e.g. %d", s, rps, int(math.Max(1, math.Round(rps))))
+	}
+	return int(rps), nil
+}
+
+// normalizeUnits returns copy of handle or suite settings with duration and rate strings converted
+// to seconds and requests per second, aliases without _sec suffix are renamed to the old keys
+func normalizeUnits(to reflect.Type, data interface{}) (interface{}, error) {
+	settings := map[interface{}]interface{}{}
+	switch m := data.(type) {
+	case map[string]interface{}:
+		for k, v := range m {
+			settings[strings.ToLower(k)] = v
+		}
+	case map[interface{}]interface{}:
+		for k, v := range m {
+			settings[strings.ToLower(fmt.Sprint(k))] = v
+		}
+	default:
+		return data, nil
+	}
+	var seconds []string
+	switch to {
+	case reflect.TypeOf(Config{}):
+		var err error
+		if settings, err = unaliasSettings(settings); err != nil {
+			return nil, err
+		}
+		seconds = []string{"attack_time_sec", "ramp_up_sec", "do_timeout_sec", "start_offset_sec"}
+		if s, ok := settings["rps"].(string); ok {
+			rps, err := ParseRate(s)
+			if err != nil {
+				return nil, fmt.Errorf("rps: %s", err)
+			}
+			settings["rps"] = rps
+		}
+	case reflect.TypeOf(SuiteConfig{}):
+		seconds = []string{"http_timeout"}
+	}
+	for _, key := range seconds {
+		if s, ok := settings[key].(string); ok {
+			sec, err := ParseSeconds(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", key, err)
+			}
+			settings[key] = sec
+		}
+	}
+	return settings, nil
+}
+
+// unaliasSettings returns copy of handle settings with aliases renamed to the old keys
+func unaliasSettings(m map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+	res := make(map[interface{}]interface{}, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+	for alias, key := range secondsKeyAliases {
+		v, ok := res[alias]
+		if !ok {
+			continue
+		}
+		if _, ok := res[key]; ok {
+			return nil, fmt.Errorf("both %s and %s are set, please keep one of them", alias, key)
+		}
+		delete(res, alias)
+		res[key] = v
+	}
+	return res, nil
+}
+
+// secondsFlag is an int flag of seconds accepting Go durations, e.g. -attack 4h
+type secondsFlag int
+
+func (f *secondsFlag) String() string {
+	return strconv.Itoa(int(*f))
+}
+
+func (f *secondsFlag) Set(s string) error {
+	sec, err := ParseSeconds(s)
+	if err != nil {
+		return err
+	}
+	*f = secondsFlag(sec)
+	return nil
+}
+
+// rateFlag is an int flag of requests per second accepting rates, e.g. -rps 300/m
+type rateFlag int
+
+func (f *rateFlag) String() string {
+	return strconv.Itoa(int(*f))
+}
+
+func (f *rateFlag) Set(s string) error {
+	rps, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*f = rateFlag(rps)
+	return nil
+}
+
+func newSecondsFlag(name string, value int, usage string) *int {
+	f := secondsFlag(value)
+	flag.Var(&f, name, usage)
+	return (*int)(&f)
+}
+
+func newRateFlag(name string, value int, usage string) *int {
+	f := rateFlag(value)
+	flag.Var(&f, name, usage)
+	return (*int)(&f)
+}
@@ -0,0 +1,111 @@
+package loadgen
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseUnits(t *testing.T) {
+	for s, want := range map[string]int{"14400": 14400, "90s": 90, "4h": 14400, "1m30s": 90} {
+		got, err := ParseSeconds(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got %v want %v", s, got, want)
+		}
+	}
+	for s, want := range map[string]int{"50": 50, "50/s": 50, "300/m": 5, "100/10s": 10, "7200/h": 2} {
+		got, err := ParseRate(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got %v want %v", s, got, want)
+		}
+	}
+	for _, s := range []string{"1500ms", "soon"} {
+		if _, err := ParseSeconds(s); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+	for _, s := range []string{"100/m", "50/x", "many"} {
+		if _, err := ParseRate(s); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+	if _, err := ParseRate("30/m"); err == nil || !strings.Contains(err.Error(), "only whole requests per second are supported, e.g. 1") {
+		t.Errorf("unexpected error for fractional rate: %v", err)
+	}
+	var f secondsFlag
+	if err := f.Set("2h"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := int(f), 7200; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestSuiteConfigUnits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer viper.Reset()
+
+	p := writeTestConfig(t, dir, "soak.yaml", `execution_mode: parallel
+http_timeout: 2m
+defaults:
+  ramp_up: 5m
+handles:
+  - name: transfer
+    rps: 300/m
+    attack_time: 4h
+    max_attackers: 10
+    do_timeout: 20s
+  - name: balance
+    rps: 2
+    attack_time_sec: 60
+    ramp_up_sec: 10
+    max_attackers: 2
+    do_timeout_sec: 5
+`)
+	if problems := ValidateSuiteConfigFile(p); len(problems) > 0 {
+		t.Fatal(problems)
+	}
+	var cfg SuiteConfig
+	if err := viper.Unmarshal(&cfg, viper.DecodeHook(configDecodeHook)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.HttpTimeout, 120; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	h := cfg.Handles[0]
+	if got, want := [4]int{h.RPS, h.AttackTimeSec, h.RampUpTimeSec, h.DoTimeoutSec}, [4]int{5, 14400, 300, 20}; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	h = cfg.Handles[1]
+	if got, want := [4]int{h.RPS, h.AttackTimeSec, h.RampUpTimeSec, h.DoTimeoutSec}, [4]int{2, 60, 10, 5}; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	p = writeTestConfig(t, dir, "bad.yaml", `execution_mode: parallel
+handles:
+  - name: transfer
+    rps: 100/m
+    attack_time: 4h
+    attack_time_sec: 60
+`)
+	problems := ValidateSuiteConfigFile(p)
+	if got, want := len(problems), 1; got != want {
+		t.Fatalf("got %v want %v", problems, want)
+	}
+	if got, want := problems[0].Line, 3; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,508 @@
+package loadgen
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	ExecutionModeParallel = "parallel"
+	ExecutionModeSequence = "sequence"
+	ExecutionModeDAG      = "dag"
+)
+
+var (
+	yamlLineRe      = regexp.MustCompile(`line (\d+)`)
+	decodeErrPathRe = regexp.MustCompile(`'([^']*)'(: ([a-z_]+): )?`)
+)
+
+// ConfigProblem is a problem of suite config, Line is zero when it is unknown
+type ConfigProblem struct {
+	File    string
+	Line    int
+	Path    string
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	loc := p.File
+	if p.Line > 0 {
+		loc += ":" + strconv.Itoa(p.Line)
+	}
+	if p.Path != "" {
+		return fmt.Sprintf("%s: %s: %s", loc, p.Path, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", loc, p.Message)
+}
+
+func (p ConfigProblem) Error() string {
+	return p.String()
+}
+
+// configSchema is a set of allowed keys of config section, keys are lowercase as viper keeps them
+type configSchema struct {
+	keys map[string]*configSchema
+	// names are keys as they are documented, used in suggestions
+	names map[string]string
+	// items is a schema of list items
+	items *configSchema
+	// free sections accept any keys, e.g. generator settings of load project
+	free bool
+}
+
+var (
+	scalarSchema = &configSchema{}
+	freeSchema   = &configSchema{free: true}
+)
+
+func sectionSchema(keys map[string]*configSchema) *configSchema {
+	s := &configSchema{keys: map[string]*configSchema{}, names: map[string]string{}}
+	for k, v := range keys {
+		s.keys[strings.ToLower(k)] = v
+		s.names[strings.ToLower(k)] = k
+	}
+	return s
+}
+
+func scalarsSchema(keys ...string) *configSchema {
+	m := make(map[string]*configSchema, len(keys))
+	for _, k := range keys {
+		m[k] = scalarSchema
+	}
+	return sectionSchema(m)
+}
+
+// structSchema returns schema of struct decoded by mapstructure tags
+func structSchema(t reflect.Type) *configSchema {
+	keys := map[string]*configSchema{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("mapstructure"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		keys[name] = typeSchema(f.Type)
+	}
+	if t == reflect.TypeOf(Config{}) {
+		for alias := range secondsKeyAliases {
+			keys[alias] = scalarSchema
+		}
+	}
+	return sectionSchema(keys)
+}
+
+func typeSchema(t reflect.Type) *configSchema {
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		return scalarSchema
+	case t.Kind() == reflect.Struct:
+		return structSchema(t)
+	case t.Kind() == reflect.Ptr:
+		return typeSchema(t.Elem())
+	case t.Kind() == reflect.Map:
+		return freeSchema
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct:
+		return &configSchema{items: structSchema(t.Elem())}
+	}
+	return scalarSchema
+}
+
+// suiteSchema is a schema of suite config: SuiteConfig fields and sections read by viper keys
+func suiteSchema() *configSchema {
+	s := structSchema(reflect.TypeOf(SuiteConfig{}))
+	sections := map[string]*configSchema{
+		"checks": scalarsSchema("handle_threshold_percent", "p_value_threshold", "baseline_runs", "generator_sample_interval_sec",
+			"generator_cpu_threshold_percent", "rate_tolerance", "blocked_sends_threshold_percent"),
+		"reports": sectionSchema(map[string]*configSchema{
+			"dir":        scalarSchema,
+			"store":      scalarSchema,
+			"junit_file": scalarSchema,
+			"sqlite":     scalarsSchema("driver", "dsn"),
+			"s3":         scalarsSchema("endpoint", "region", "bucket", "prefix", "access_key", "secret_key"),
+		}),
+		"graphite": scalarsSchema("url", "flushDurationSec", "loadGeneratorPrefix"),
+		"grafana":  scalarsSchema("url"),
+		"prometheus": sectionSchema(map[string]*configSchema{
+			"url":                           scalarSchema,
+			"env_label":                     scalarSchema,
+			"namespace":                     scalarSchema,
+			"pulse_diff_check_interval_sec": scalarSchema,
+			"pulse_lag_threshold":           scalarSchema,
+			"pulse_lag_query":               scalarSchema,
+			"opened_requests_threshold":     scalarSchema,
+			"opened_requests_query":         scalarSchema,
+			"guards":                        typeSchema(reflect.TypeOf([]Guard{})),
+		}),
+		"tracing":          scalarsSchema("otlp_url", "service_name", "flushDurationSec"),
+		"redaction":        scalarsSchema("keys", "values", "mask", "replace_defaults"),
+		"notify":           typeSchema(reflect.TypeOf([]NotifierConfig{})),
+		"generator":        freeSchema,
+		"targets":          scalarSchema,
+		"hot_reload":       scalarSchema,
+		"timezone":         scalarSchema,
+		"dashboard_dir":    scalarSchema,
+		"load_scripts_dir": scalarSchema,
+		configDefaultsKey:  structSchema(reflect.TypeOf(Config{})),
+		configIncludeKey:   scalarSchema,
+		configExtendsKey:   scalarSchema,
+	}
+	for k, v := range sections {
+		s.keys[strings.ToLower(k)] = v
+		s.names[strings.ToLower(k)] = k
+	}
+	return s
+}
+
+// suggestKey returns documented key differing from unknown key only by case and underscores
+func (s *configSchema) suggestKey(key string) string {
+	norm := func(k string) string {
+		return strings.Replace(strings.ToLower(k), "_", "", -1)
+	}
+	for k, name := range s.names {
+		if norm(k) == norm(key) {
+			return name
+		}
+	}
+	return ""
+}
+
+// unknownKeys walks raw yaml document and returns problems of keys missing in schema
+func (s *configSchema) unknownKeys(v interface{}, path string, idx yamlIndex, file string) []ConfigProblem {
+	var res []ConfigProblem
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		if s.free {
+			return nil
+		}
+		if s.keys == nil {
+			if s.items == nil {
+				return []ConfigProblem{{File: file, Line: idx.line(path), Path: path, Message: "must be a value, not a section"}}
+			}
+			return nil
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, fmt.Sprint(k))
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			kp := joinConfigPath(path, k)
+			child, ok := s.keys[strings.ToLower(k)]
+			if !ok {
+				msg := fmt.Sprintf("unknown key %q", k)
+				if suggestion := s.suggestKey(k); suggestion != "" {
+					msg += fmt.Sprintf(", did you mean %q?", suggestion)
+				}
+				res = append(res, ConfigProblem{File: file, Line: idx.line(kp), Path: kp, Message: msg})
+				continue
+			}
+			res = append(res, child.unknownKeys(v[k], kp, idx, file)...)
+		}
+	case []interface{}:
+		if s.items == nil {
+			return nil
+		}
+		for i, item := range v {
+			res = append(res, s.items.unknownKeys(item, fmt.Sprintf("%s[%d]", path, i), idx, file)...)
+		}
+	}
+	return res
+}
+
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// ValidateSuiteConfigFile reads suite config into viper and checks it, problems have file and line when possible
+func ValidateSuiteConfigFile(path string) []ConfigProblem {
+	return ValidateOverriddenSuiteConfigFile(path, SuiteOverrides{})
+}
+
+// ValidateOverriddenSuiteConfigFile reads suite config into viper, applies command line overrides and checks it
+func ValidateOverriddenSuiteConfigFile(path string, overrides SuiteOverrides) []ConfigProblem {
+	var problems []ConfigProblem
+	idx := yamlIndex{}
+	viper.SetConfigFile(path)
+	if isYAMLFile(path) {
+		cfg, err := composeSuiteConfig(path)
+		if err != nil {
+			return []ConfigProblem{configProblem(path, err)}
+		}
+		// unknown keys are checked in every file to report their lines
+		for _, src := range cfg.sources {
+			problems = append(problems, suiteSchema().unknownKeys(src.raw, "", src.idx, src.path)...)
+		}
+		idx = cfg.sources[0].idx
+		if err := cfg.readInto(viper.GetViper()); err != nil {
+			return append(problems, configProblem(path, err))
+		}
+	} else if err := viper.ReadInConfig(); err != nil {
+		return []ConfigProblem{configProblem(path, err)}
+	}
+	for _, p := range overrides.Apply(viper.GetViper()) {
+		p.File = path
+		problems = append(problems, p)
+	}
+
+	var suiteCfg SuiteConfig
+	if err := viper.Unmarshal(&suiteCfg, viper.DecodeHook(configDecodeHook)); err != nil {
+		return append(problems, decodeProblems(err, idx, path)...)
+	}
+	add := func(p, msg string) {
+		problems = append(problems, ConfigProblem{File: path, Line: idx.line(p), Path: p, Message: msg})
+	}
+	mode := viper.GetString("execution_mode")
+	switch mode {
+	case ExecutionModeParallel, ExecutionModeSequence, ExecutionModeDAG:
+	case "":
+		add("execution_mode", "please set execution mode, possible values are {parallel,sequence,dag}")
+	default:
+		add("execution_mode", fmt.Sprintf("unknown execution mode [%s], possible values are {parallel,sequence,dag}", mode))
+	}
+	if len(suiteCfg.Handles) == 0 {
+		add("handles", "please set at least one handle")
+	}
+	for _, msg := range ValidateThresholds(suiteCfg.Thresholds) {
+		add("thresholds", msg)
+	}
+	names := map[string]int{}
+	for i, h := range suiteCfg.Handles {
+		hp := fmt.Sprintf("handles[%d]", i)
+		if h.HandleName == "" {
+			add(hp, "please set handle name")
+		} else if j, ok := names[h.HandleName]; ok {
+			add(hp, fmt.Sprintf("handle name [%s] is already used by handles[%d]", h.HandleName, j))
+		} else {
+			names[h.HandleName] = i
+		}
+		for _, msg := range h.Validate() {
+			add(hp, msg)
+		}
+		if mode != ExecutionModeDAG && (len(h.DependsOn) > 0 || h.StartOffsetSec > 0) {
+			add(hp, fmt.Sprintf("depends_on and start_offset_sec require execution_mode dag, not [%s]", mode))
+		}
+	}
+	for i, msgs := range dependencyProblems(suiteCfg.Handles) {
+		for _, msg := range msgs {
+			add(fmt.Sprintf("handles[%d].depends_on", i), msg)
+		}
+	}
+	var guards []Guard
+	if err := viper.UnmarshalKey("prometheus.guards", &guards); err != nil {
+		add("prometheus.guards", err.Error())
+	}
+	for i, g := range guards {
+		for _, msg := range g.Validate() {
+			add(fmt.Sprintf("prometheus.guards[%d]", i), msg)
+		}
+	}
+	var notifiers []NotifierConfig
+	if err := viper.UnmarshalKey("notify", &notifiers, viper.DecodeHook(configDecodeHook)); err != nil {
+		add("notify", err.Error())
+	}
+	for i, n := range notifiers {
+		if _, err := NewNotifier(n); err != nil {
+			add(fmt.Sprintf("notify[%d]", i), err.Error())
+		}
+	}
+	if _, err := NewRedactionPolicy(viper.GetStringSlice("redaction.keys"), viper.GetStringSlice("redaction.values"), ""); err != nil {
+		add("redaction", err.Error())
+	}
+	switch store := viper.GetString("reports.store"); store {
+	case "", ReportStoreFS, ReportStoreS3:
+	case ReportStoreSQLite:
+		driver := viper.GetString("reports.sqlite.driver")
+		if driver == "" {
+			driver = DefaultSQLDriver
+		}
+		if p := sqlDriverProblem(driver); p != "" {
+			add("reports.sqlite.driver", p)
+		}
+		if viper.GetString("reports.sqlite.dsn") == "" {
+			add("reports.sqlite.dsn", "please set database dsn, e.g. load/reports.db")
+		}
+	default:
+		add("reports.store", fmt.Sprintf("unknown report store [%s], possible values are {fs,sqlite,s3}", store))
+	}
+	return problems
+}
+
+// configProblem returns error as problem of file, line is taken from yaml error message
+func configProblem(file string, err error) ConfigProblem {
+	if p, ok := err.(ConfigProblem); ok {
+		return p
+	}
+	p := ConfigProblem{File: file, Message: err.Error()}
+	if m := yamlLineRe.FindStringSubmatch(err.Error()); m != nil {
+		p.Line, _ = strconv.Atoi(m[1])
+	}
+	return p
+}
+
+// decodeProblems splits mapstructure decoding error into problems, e.g. * cannot parse 'handles[0].rps' as int
+func decodeProblems(err error, idx yamlIndex, file string) []ConfigProblem {
+	var res []ConfigProblem
+	for _, l := range strings.Split(err.Error(), "\n") {
+		l = strings.TrimSpace(l)
+		if !strings.HasPrefix(l, "* ") {
+			continue
+		}
+		l = strings.TrimPrefix(l, "* ")
+		p := ConfigProblem{File: file, Message: l}
+		if m := decodeErrPathRe.FindStringSubmatch(l); m != nil {
+			// decode hook errors are prefixed with the key, e.g. error decoding 'handles[0]': rps: bad rate
+			path := m[1]
+			if m[3] != "" {
+				path = joinConfigPath(path, m[3])
+			}
+			p.Line = idx.line(path)
+		}
+		res = append(res, p)
+	}
+	if len(res) == 0 {
+		res = append(res, ConfigProblem{File: file, Message: err.Error()})
+	}
+	return res
+}
+
+func isYAMLFile(path string) bool {
+	return strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")
+}
+
+// yamlIndex maps lowercase key paths of block yaml document to line numbers, e.g. handles[0].rps
+type yamlIndex map[string]int
+
+// line returns line of path or of its nearest parent
+func (idx yamlIndex) line(path string) int {
+	path = strings.ToLower(path)
+	for path != "" {
+		if l, ok := idx[path]; ok {
+			return l
+		}
+		cut := strings.LastIndexAny(path, ".[")
+		if cut < 0 {
+			break
+		}
+		path = path[:cut]
+	}
+	return 0
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+func newYAMLIndex(data []byte) yamlIndex {
+	var lines []yamlLine
+	for i, l := range strings.Split(string(data), "\n") {
+		text := strings.TrimSpace(l)
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" {
+			continue
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(l) - len(strings.TrimLeft(l, " ")), text: text})
+	}
+	idx := yamlIndex{}
+	if len(lines) > 0 {
+		idx.block(lines, 0, "")
+	}
+	return idx
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// mappingKey returns key and inline value of "key: value" line
+func mappingKey(text string) (string, string, bool) {
+	i := strings.Index(text, ": ")
+	if strings.HasSuffix(text, ":") && (i < 0 || i == len(text)-1) {
+		i = len(text) - 1
+	}
+	if i <= 0 || strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		return "", "", false
+	}
+	return strings.Trim(text[:i], `"'`), strings.TrimSpace(text[i+1:]), true
+}
+
+// block indexes node starting at line i with entries at its indent and returns next line after it
+func (idx yamlIndex) block(lines []yamlLine, i int, path string) int {
+	ind := lines[i].indent
+	if isSeqItem(lines[i].text) {
+		for n := 0; i < len(lines) && lines[i].indent == ind && isSeqItem(lines[i].text); n++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, n)
+			idx[itemPath] = lines[i].num
+			rest := strings.TrimSpace(strings.TrimPrefix(lines[i].text, "-"))
+			if _, _, ok := mappingKey(rest); ok {
+				// "- key: value" starts mapping at the indent of key
+				item := lines[i]
+				item.indent = ind + len(item.text) - len(rest)
+				item.text = rest
+				sub := append([]yamlLine{item}, lines[i+1:]...)
+				i += idx.block(sub, 0, itemPath)
+				continue
+			}
+			i = skipYAMLChildren(lines, i+1, ind)
+		}
+		return i
+	}
+	for i < len(lines) && lines[i].indent == ind && !isSeqItem(lines[i].text) {
+		key, value, ok := mappingKey(lines[i].text)
+		if !ok {
+			return skipYAMLChildren(lines, i+1, ind)
+		}
+		kp := strings.ToLower(joinConfigPath(path, key))
+		idx[kp] = lines[i].num
+		i++
+		nested := value == "" && i < len(lines) &&
+			(lines[i].indent > ind || lines[i].indent == ind && isSeqItem(lines[i].text))
+		if nested {
+			i = idx.block(lines, i, kp)
+			continue
+		}
+		i = skipYAMLChildren(lines, i, ind)
+	}
+	return i
+}
+
+// skipYAMLChildren skips multiline values, e.g. block scalars
+func skipYAMLChildren(lines []yamlLine, i int, ind int) int {
+	for i < len(lines) && lines[i].indent > ind {
+		i++
+	}
+	return i
+}
+
+func runValidateCommand(args []string) {
+	fs := newCommandFlagSet("validate")
+	var overrides SuiteOverrides
+	overrides.RegisterFlags(fs)
+	parseCommandArgs(fs, args, 1)
+	failed := false
+	for _, path := range fs.Args() {
+		problems := ValidateOverriddenSuiteConfigFile(path, overrides)
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		if len(problems) > 0 {
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: ok\n", path)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,124 @@
+package loadgen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func writeTestConfig(t *testing.T, dir string, name string, data string) string {
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestValidateSuiteConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer viper.Reset()
+
+	p := writeTestConfig(t, dir, "bad.yaml", `execution_mode: paralel
+checks:
+  handle_threshold_percent: 1.2
+  baseline_run: 5
+generator:
+  target: http://localhost
+handles:
+  - name: transfer
+    rps: 10
+    attack_time_sec: 60
+    ramp_up_sec: 10
+    max_attackers: 10
+    do_timeout_sec: 5
+  - name: balance
+    rps: 2
+    rampUpSec: 1
+    attack_time_sec: 2
+    max_attackers: 2
+    do_timeout_sec: 5
+    thresholds:
+      - p99 <> 300ms
+`)
+	var got []string
+	for _, p := range ValidateSuiteConfigFile(p) {
+		got = append(got, strings.TrimPrefix(p.String(), dir+string(filepath.Separator)))
+	}
+	want := []string{
+		`bad.yaml:4: checks.baseline_run: unknown key "baseline_run"`,
+		`bad.yaml:16: handles[1].rampUpSec: unknown key "rampUpSec", did you mean "ramp_up_sec"?`,
+		`bad.yaml:1: execution_mode: unknown execution mode [paralel], possible values are {parallel,sequence,dag}`,
+		`bad.yaml:14: handles[1]: please set the attack time to a positive number of seconds > 0`,
+		`bad.yaml:14: handles[1]: threshold 0: `,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("got %v want %v", got[i], want[i])
+		}
+	}
+
+	p = writeTestConfig(t, dir, "types.yaml", `execution_mode: parallel
+handles:
+  - name: transfer
+    rps: many
+`)
+	problems := ValidateSuiteConfigFile(p)
+	if got, want := len(problems), 1; got != want {
+		t.Fatalf("got %v want %v", problems, want)
+	}
+	if got, want := problems[0].Line, 4; got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	p = writeTestConfig(t, dir, "ok.yaml", `execution_mode: sequence
+handles:
+  - name: transfer
+    rps: 10
+    attack_time_sec: 60
+    ramp_up_sec: 10
+    max_attackers: 10
+    do_timeout_sec: 5
+`)
+	if problems := ValidateSuiteConfigFile(p); len(problems) > 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
+
+func TestYAMLIndex(t *testing.T) {
+	idx := newYAMLIndex([]byte(`# suite
+reports:
+  s3:
+    bucket: load
+handles:
+- name: a
+  description: |
+    multi
+    line
+- name: b
+  thresholds:
+    - metric: p99
+      absolute: 300
+`))
+	for path, want := range map[string]int{
+		"reports.s3.bucket":                 4,
+		"handles[0].description":            7,
+		"handles[1]":                        10,
+		"handles[1].thresholds[0].absolute": 13,
+		"handles[1].thresholds[0].unknown":  12,
+	} {
+		if got := idx.line(path); got != want {
+			t.Errorf("%s: got %v want %v", path, got, want)
+		}
+	}
+}